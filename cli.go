@@ -0,0 +1,660 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+//Exit codes used by the non-interactive commands
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+	//exitTimeout returned by wait when no matching message arrived in time
+	exitTimeout = 3
+	//exitUnreachable the provider could not be reached
+	exitUnreachable = 4
+	//exitLayoutChanged the provider page could not be parsed
+	exitLayoutChanged = 5
+	//exitNotFound the number is not saved or not offered
+	exitNotFound = 6
+	//exitDBCorrupt the local DB file is damaged
+	exitDBCorrupt = 7
+	//exitDuplicate the number is already saved
+	exitDuplicate = 8
+	//exitBlocked the provider refused the request or answered with a bot challenge
+	exitBlocked = 9
+)
+
+const usageText = `Usage: fake-sms [global options] [command]
+
+Run without a command to open the interactive menu.
+
+Global options:
+  --config FILE                         config file, $FAKE_SMS_CONFIG or
+                                        ~/.config/fake-sms/config.yaml by default
+  --provider NAME                       provider used when none is named, all
+                                        lists the numbers of every provider
+  --store json|bolt                     DB backend
+  --db-dir DIR                          directory holding the DB
+  --http-timeout 30s                    timeout of a single HTTP request
+  --http-retries 3                      retries of requests failing with network
+                                        errors, 5xx or 429 responses
+  --user-agent UA                       User-Agent sent to providers
+  --proxy URL                           proxy for provider requests: http, https,
+                                        socks5 or socks5h URL, or direct to ignore
+                                        HTTP(S)_PROXY
+  --provider-proxies NAME=URL,...       proxies of single providers
+  --output FORMAT                       output format of listings: table, json,
+                                        jsonl, csv, yaml or tsv
+  --debug-dump DIR                      save the raw pages fetched from providers,
+                                        for bug reports
+  --export=false                        do not write message dumps
+  --export-dir DIR                      directory message dumps are written to
+  --export-name TEMPLATE                dump file name, with {number}, {provider},
+                                        {country}, {timestamp} and {ext}
+  --export-format FORMAT                dump format: json, jsonl, csv or mbox
+  --export-mode overwrite|append        replace dumps or add new messages to them
+
+Settings are taken from the command flags, then the global options, then the
+FAKE_SMS_* environment variables, then the config file. Run config show to see
+the effective values and where they came from.
+
+Commands:
+  numbers available [--provider NAME] [--country DE]
+                                        list numbers offered by a provider, --country
+                                        takes an ISO code or a country name.
+                                        --provider all asks every provider at once
+                                        and lists the ones that failed on stderr
+  numbers add NUMBER [--provider NAME]  save an available number
+  numbers add --country DE [--provider NAME]
+                                        save the first unused number of a country,
+                                        from any provider with --provider all
+  numbers list                          list saved numbers with their health and last check
+  numbers rm NUMBER                     remove a saved number
+  messages NUMBER [--filter REGEX] [--new] [--since 2h]
+                                        fetch messages received by a number and store
+                                        them, --new prints only the ones not seen before
+  history NUMBER [--filter REGEX] [--unread] [--since 2h]
+                                        list stored messages without going online
+  health [NUMBER...] [--idle-after 72h] [--rotate]
+                                        check whether saved numbers are active, idle
+                                        or dead, --rotate replaces dead numbers with
+                                        unused ones, preferring the same country
+  prune [NUMBER] [--older-than 720h]    delete old stored messages of one or all
+                                        saved numbers
+  read NUMBER [KEY...]                  mark stored messages as read, all without keys
+  unread NUMBER [KEY...]                mark stored messages as unread
+  wait NUMBER [--filter REGEX] [--interval 5s] [--max-interval 30s] [--timeout 5m]
+                                        wait for a new message matching the filter
+                                        and print the first capture group, or the
+                                        detected code when the filter has none
+  mock send --to NUMBER --body TEXT [--from SENDER]
+                                        deliver a message to a number of the offline
+                                        mock provider, e.g. --provider mock
+  serve [--addr 127.0.0.1:8080]        serve a JSON REST API, described at /openapi.json,
+                                        on every interface with --addr :8080
+  config show                           print the effective settings and their source
+  help                                  show this message
+
+The listings of numbers, messages and health accept --output FORMAT as well.
+table aligns the columns under a header and cuts long values, tsv prints the
+same columns tab separated without header like earlier versions did, the other
+formats print every field. Numbers are printed in E.164 form.
+
+Exit codes:
+  0  success                    4  provider unreachable
+  1  other failure              5  provider page layout changed
+  2  invalid arguments          6  number not found
+  3  wait timed out             7  local DB corrupt
+                                8  number already saved
+                                9  provider blocked the request or served
+                                   a bot challenge
+`
+
+//usageError an error caused by invalid arguments, reported with exitUsage
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func newUsageError(format string, args ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+//runCommand Runs a non-interactive command and returns the process exit code
+func runCommand(client *fakesms.Client, cfg *config, args []string, stdout, stderr io.Writer) int {
+	return reportCommandError(dispatchCommand(client, cfg, args, stdout, stderr), stdout, stderr)
+}
+
+//reportCommandError prints a failed command and returns the matching exit code
+func reportCommandError(err error, stdout, stderr io.Writer) int {
+	if err == nil {
+		return exitOK
+	}
+
+	if err == flag.ErrHelp {
+		fmt.Fprint(stdout, usageText)
+		return exitOK
+	}
+
+	fmt.Fprintf(stderr, "fake-sms: %s\n", err)
+	_, isUsage := err.(usageError)
+	if isUsage || errors.Is(err, fakesms.ErrUnknownProvider) || errors.Is(err, fakesms.ErrInvalidNumber) {
+		fmt.Fprint(stderr, usageText)
+		return exitUsage
+	}
+	return exitCodeFor(err)
+}
+
+//exitCodeFor maps the error kinds to distinct exit codes
+func exitCodeFor(err error) int {
+	switch {
+	case errors.Is(err, fakesms.ErrWaitTimeout):
+		return exitTimeout
+	case errors.Is(err, fakesms.ErrProviderUnreachable):
+		return exitUnreachable
+	case errors.Is(err, fakesms.ErrBlocked):
+		return exitBlocked
+	case errors.Is(err, fakesms.ErrLayoutChanged):
+		return exitLayoutChanged
+	case errors.Is(err, fakesms.ErrNumberNotFound):
+		return exitNotFound
+	case errors.Is(err, fakesms.ErrDBCorrupt):
+		return exitDBCorrupt
+	case errors.Is(err, fakesms.ErrDuplicateNumber):
+		return exitDuplicate
+	default:
+		return exitError
+	}
+}
+
+func dispatchCommand(client *fakesms.Client, cfg *config, args []string, stdout, stderr io.Writer) error {
+	switch args[0] {
+	case "numbers":
+		if len(args) < 2 {
+			return newUsageError("numbers requires a sub-command")
+		}
+		switch args[1] {
+		case "available":
+			return cmdNumbersAvailable(client, cfg, args[2:], stdout, stderr)
+		case "add":
+			return cmdNumbersAdd(client, cfg, args[2:], stdout)
+		case "list", "ls":
+			return cmdNumbersList(client, cfg, args[2:], stdout)
+		case "rm", "remove":
+			return cmdNumbersRemove(client, args[2:], stdout)
+		default:
+			return newUsageError("unknown numbers sub-command %q", args[1])
+		}
+	case "messages":
+		return cmdMessages(client, cfg, args[1:], stdout)
+	case "history":
+		return cmdHistory(client, cfg, args[1:], stdout)
+	case "health":
+		return cmdHealth(client, cfg, args[1:], stdout)
+	case "prune":
+		return cmdPrune(client, args[1:], stdout)
+	case "read":
+		return cmdMarkRead(client, "read", true, args[1:], stdout)
+	case "unread":
+		return cmdMarkRead(client, "unread", false, args[1:], stdout)
+	case "wait":
+		return cmdWait(client, cfg, args[1:], stdout)
+	case "mock":
+		if len(args) < 2 || args[1] != "send" {
+			return newUsageError("mock requires the send sub-command")
+		}
+		return cmdMockSend(client, cfg, args[2:], stdout)
+	case "serve":
+		return cmdServe(client, cfg, args[1:], stdout)
+	case "config":
+		return cmdConfig(cfg, args[1:], stdout)
+	case "help", "-h", "-help", "--help":
+		return flag.ErrHelp
+	default:
+		return newUsageError("unknown command %q", args[0])
+	}
+}
+
+//parseArgs Parses flags that may appear before, between or after positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(ioutil.Discard)
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+			return nil, newUsageError("%s: %s", fs.Name(), err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func expectArgs(fs *flag.FlagSet, positional []string, count int) error {
+	if len(positional) != count {
+		return newUsageError("%s expects %d argument(s), got %d", fs.Name(), count, len(positional))
+	}
+	return nil
+}
+
+//formatTime prints times as RFC 3339, unknown times as -
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+//parseSince accepts a duration back from now ("2h") or an RFC 3339 time, "" means no limit
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, newUsageError("invalid since %q, expected a duration like 2h or an RFC 3339 time", value)
+	}
+	return since, nil
+}
+
+//singleLine makes free text safe to print as a tab separated field
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+//orDash prints empty fields as -
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+//cmdNumbersAvailable lists the numbers of a provider. With --provider all the providers that fail
+//are reported on stderr while the numbers of the others are listed
+func cmdNumbersAvailable(client *fakesms.Client, cfg *config, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("numbers available", flag.ContinueOnError)
+	providerName := fs.String("provider", cfg.String("provider"), "provider to list numbers from, all to ask every provider")
+	country := fs.String("country", "", "only list numbers of this country, an ISO code or a name")
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	var numbers fakesms.Numbers
+	if *providerName == fakesms.AllProviders {
+		var failures []fakesms.ProviderFailure
+		numbers, failures, err = client.AvailableNumbersFromAll(context.Background())
+		if err != nil {
+			return err
+		}
+		for _, failure := range failures {
+			fmt.Fprintf(stderr, "fake-sms: skipped %s\n", failure)
+		}
+	} else if numbers, err = client.AvailableNumbers(context.Background(), *providerName); err != nil {
+		return err
+	}
+
+	return numbersListing(numbers.ByCountry(*country)).write(stdout, *output)
+}
+
+func cmdNumbersAdd(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("numbers add", flag.ContinueOnError)
+	providerName := fs.String("provider", cfg.String("provider"), "provider offering the number")
+	country := fs.String("country", "", "save the first unused number of this country, an ISO code or a name")
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if *country != "" {
+		err = expectArgs(fs, positional, 0)
+	} else {
+		err = expectArgs(fs, positional, 1)
+	}
+	if err != nil {
+		return err
+	}
+
+	var selectedNumber *fakesms.Number
+	if *country != "" {
+		selectedNumber, err = client.FirstAvailable(context.Background(), *providerName, *country)
+	} else {
+		selectedNumber, err = client.AvailableNumber(context.Background(), *providerName, positional[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = client.Store.AddNumber(selectedNumber); err != nil {
+		return err
+	}
+	return numbersListing(fakesms.Numbers{*selectedNumber}).write(stdout, *output)
+}
+
+func cmdNumbersList(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("numbers list", flag.ContinueOnError)
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	numbers, err := client.Store.ListNumbers()
+	if err != nil {
+		return err
+	}
+	lastChecks := make([]time.Time, len(numbers))
+	for idx := range numbers {
+		if lastChecks[idx], err = client.LastCheck(numbers[idx].Number); err != nil {
+			return err
+		}
+	}
+
+	return savedNumbersListing(numbers, lastChecks).write(stdout, *output)
+}
+
+func cmdNumbersRemove(client *fakesms.Client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("numbers rm", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 1); err != nil {
+		return err
+	}
+
+	return client.Store.RemoveNumber(positional[0])
+}
+
+//filterFlag validates the --filter value, an empty filter matches everything
+func filterFlag(filter string) error {
+	if filter == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(filter); err != nil {
+		return newUsageError("invalid filter: %s", err)
+	}
+	return nil
+}
+
+//applyFilter runs the --filter value over the messages
+func applyFilter(filter string, messages fakesms.Messages) (fakesms.Messages, error) {
+	if filter == "" {
+		return messages, nil
+	}
+	return fakesms.FilterMessages(filter, messages)
+}
+
+func cmdMessages(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("messages", flag.ContinueOnError)
+	filter := fs.String("filter", cfg.String("filter"), "only print messages whose body matches this regular expression")
+	providerName := fs.String("provider", "", "provider to query when the number is not saved")
+	onlyNew := fs.Bool("new", false, "only print messages that were not fetched before")
+	sinceFlag := fs.String("since", "", "only print messages received after this duration ago or RFC 3339 time")
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 1); err != nil {
+		return err
+	}
+	if err = filterFlag(*filter); err != nil {
+		return err
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	number, err := client.ResolveNumber(positional[0], *providerName)
+	if err != nil {
+		return err
+	}
+
+	messages, fresh, err := client.Fetch(context.Background(), number)
+	if err != nil {
+		return err
+	}
+	if *onlyNew {
+		messages = fresh
+	}
+	messages = messages.Since(since)
+	messages.SortNewestFirst()
+
+	if messages, err = applyFilter(*filter, messages); err != nil {
+		return err
+	}
+	return messagesListing(messages).write(stdout, *output)
+}
+
+func cmdHistory(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	filter := fs.String("filter", cfg.String("filter"), "only print messages whose body matches this regular expression")
+	unread := fs.Bool("unread", false, "only print messages that are not marked as read")
+	sinceFlag := fs.String("since", "", "only print messages received after this duration ago or RFC 3339 time")
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 1); err != nil {
+		return err
+	}
+	if err = filterFlag(*filter); err != nil {
+		return err
+	}
+	since, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	messages, err := client.History(positional[0])
+	if err != nil {
+		return err
+	}
+	if *unread {
+		messages = messages.Unread()
+	}
+	messages = messages.Since(since)
+
+	if messages, err = applyFilter(*filter, messages); err != nil {
+		return err
+	}
+	return messagesListing(messages).write(stdout, *output)
+}
+
+func cmdHealth(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	idleAfter := fs.Duration("idle-after", fakesms.DefaultIdleAfter, "mark numbers without messages for this long as idle")
+	rotate := fs.Bool("rotate", false, "replace dead numbers with unused ones from the same provider")
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if *idleAfter <= 0 {
+		return newUsageError("idle-after must be positive")
+	}
+
+	reports, err := client.CheckAll(context.Background(), *idleAfter, *rotate, positional...)
+	//the numbers checked before a failure are still worth printing
+	if writeErr := healthListing(reports).write(stdout, *output); err == nil {
+		err = writeErr
+	}
+	return err
+}
+
+func cmdPrune(client *fakesms.Client, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 30*24*time.Hour, "delete stored messages received longer ago than this")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return newUsageError("prune expects at most one number, got %d", len(positional))
+	}
+	if *olderThan <= 0 {
+		return newUsageError("older-than must be positive")
+	}
+
+	number := ""
+	if len(positional) == 1 {
+		number = positional[0]
+	}
+
+	removed, err := client.PruneMessages(number, time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, removed)
+	return nil
+}
+
+func cmdMockSend(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("mock send", flag.ContinueOnError)
+	to := fs.String("to", "", "number receiving the message")
+	from := fs.String("from", "fake-sms", "sender shown for the message")
+	body := fs.String("body", "", "text of the message")
+	output := outputFlag(fs, cfg)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 0); err != nil {
+		return err
+	}
+	if err = checkOutput(*output); err != nil {
+		return err
+	}
+	if *to == "" || strings.TrimSpace(*body) == "" {
+		return newUsageError("mock send requires --to and --body")
+	}
+
+	mock, err := client.Mock()
+	if err != nil {
+		return err
+	}
+	message, err := mock.Send(*to, *from, *body, time.Now())
+	if err != nil {
+		return err
+	}
+	return messagesListing(fakesms.Messages{*message}).write(stdout, *output)
+}
+
+//cmdMarkRead implements both read and unread
+func cmdMarkRead(client *fakesms.Client, name string, read bool, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return newUsageError("%s expects a number and optionally message keys", name)
+	}
+
+	changed, err := client.MarkRead(positional[0], read, positional[1:]...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, changed)
+	return nil
+}
+
+func cmdWait(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("wait", flag.ContinueOnError)
+	defaultFilter := cfg.String("filter")
+	if defaultFilter == "" {
+		defaultFilter = `.*`
+	}
+	filter := fs.String("filter", defaultFilter, "regular expression the message body must match, its first capture group is printed")
+	providerName := fs.String("provider", "", "provider to query when the number is not saved")
+	interval := fs.Duration("interval", cfg.Duration("poll.interval"), "initial delay between polls")
+	maxInterval := fs.Duration("max-interval", cfg.Duration("poll.max_interval"), "upper bound for the delay between polls")
+	timeout := fs.Duration("timeout", cfg.Duration("poll.timeout"), "give up after this long")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 1); err != nil {
+		return err
+	}
+
+	if err = filterFlag(*filter); err != nil {
+		return err
+	}
+	if *interval <= 0 || *timeout <= 0 {
+		return newUsageError("interval and timeout must be positive")
+	}
+
+	number, err := client.ResolveNumber(positional[0], *providerName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	code, err := client.WaitForOTP(ctx, number, fakesms.WaitOptions{
+		Pattern:     *filter,
+		Interval:    *interval,
+		MaxInterval: *maxInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, code)
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+//stubProvider offers a German number and the messages tests give it, or fails with err
+type stubProvider struct {
+	err      error
+	messages []fakesms.Message
+}
+
+func (p *stubProvider) Name() string {
+	return "stub"
+}
+
+func (p *stubProvider) AvailableNumbers(ctx context.Context) ([]fakesms.Number, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []fakesms.Number{{Number: "+4915735983768", Country: "Germany"}}, nil
+}
+
+func (p *stubProvider) Messages(ctx context.Context, number string) ([]fakesms.Message, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return append([]fakesms.Message{}, p.messages...), nil
+}
+
+//tempDir creates a directory that is removed when the test ends
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "fake-sms-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+//newTestClient loads the configuration of a DB in a temporary directory, using a stub provider
+//that is registered until the test ends
+func newTestClient(t *testing.T) (*fakesms.Client, *config, *stubProvider) {
+	stub := &stubProvider{}
+	fakesms.Register("stub", func(options fakesms.ProviderOptions) fakesms.Provider {
+		return stub
+	})
+	t.Cleanup(func() {
+		fakesms.Unregister("stub")
+	})
+
+	dir := tempDir(t)
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	lookupEnv := func(name string) (string, bool) {
+		return "", false
+	}
+	cfg, _, err := loadConfig([]string{"--config", path, "--db-dir", dir, "--provider", "stub"}, lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, cfg, stub
+}
+
+//corruptStore a store whose DB file cannot be read
+type corruptStore struct {
+	fakesms.Store
+}
+
+func (corruptStore) ListNumbers() (fakesms.Numbers, error) {
+	return nil, fmt.Errorf("%w: db.json is damaged", fakesms.ErrDBCorrupt)
+}
+
+func TestRunCommandExitCodes(t *testing.T) {
+	client, cfg, stub := newTestClient(t)
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runCommand(client, cfg, args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, stdout, _ := run("numbers", "available", "--output", "tsv")
+	if code != exitOK || !strings.HasPrefix(stdout, "+4915735983768\tGermany\tstub\t") {
+		t.Errorf("expected the stub number, got %d %q", code, stdout)
+	}
+	if code, stdout, _ = run("numbers", "add", "+49 1573 5983768", "--output", "tsv"); code != exitOK || stdout == "" {
+		t.Errorf("expected the number to be saved, got %d %q", code, stdout)
+	}
+
+	for _, test := range []struct {
+		name string
+		args []string
+		err  error
+		want int
+	}{
+		{"usage", []string{"numbers", "add"}, nil, exitUsage},
+		{"unknown flag", []string{"history", "+4915735983768", "--unknown"}, nil, exitUsage},
+		{"unknown command", []string{"frobnicate"}, nil, exitUsage},
+		{"not offered", []string{"numbers", "add", "+447700900123"}, nil, exitNotFound},
+		{"not saved", []string{"numbers", "rm", "+447700900123"}, nil, exitNotFound},
+		{"already saved", []string{"numbers", "add", "+4915735983768"}, nil, exitDuplicate},
+		{"unreachable", []string{"numbers", "available"}, fmt.Errorf("%w: connection refused", fakesms.ErrProviderUnreachable), exitUnreachable},
+		{"blocked", []string{"messages", "+4915735983768"}, fmt.Errorf("%w: 403 Forbidden", fakesms.ErrBlocked), exitBlocked},
+		{"layout", []string{"numbers", "available"}, fmt.Errorf("%w: no boxes", fakesms.ErrLayoutChanged), exitLayoutChanged},
+		{"wait timeout", []string{"wait", "+4915735983768", "--interval", "10ms", "--timeout", "50ms"}, nil, exitTimeout},
+	} {
+		stub.err = test.err
+		code, stdout, stderr := run(test.args...)
+		if code != test.want {
+			t.Errorf("%s: expected exit code %d, got %d (%s)", test.name, test.want, code, stderr)
+		}
+		if stdout != "" || !strings.HasPrefix(stderr, "fake-sms: ") {
+			t.Errorf("%s: expected the error on stderr only, got %q and %q", test.name, stdout, stderr)
+		}
+		if usage := strings.Contains(stderr, "Usage:"); usage != (test.want == exitUsage) {
+			t.Errorf("%s: usage printed %t, expected %t", test.name, usage, test.want == exitUsage)
+		}
+	}
+	stub.err = nil
+
+	client.Store = corruptStore{client.Store}
+	if code, _, stderr := run("numbers", "list"); code != exitDBCorrupt {
+		t.Errorf("expected exit code %d for a corrupt DB, got %d (%s)", exitDBCorrupt, code, stderr)
+	}
+
+	if code, stdout, _ = run("help"); code != exitOK || !strings.HasPrefix(stdout, "Usage:") {
+		t.Errorf("expected help on stdout, got %d %q", code, stdout)
+	}
+	for code := exitOK; code <= exitBlocked; code++ {
+		if !strings.Contains(stdout, fmt.Sprintf(" %d  ", code)) {
+			t.Errorf("expected exit code %d to be explained in the help", code)
+		}
+	}
+}
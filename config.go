@@ -0,0 +1,596 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+	"gopkg.in/yaml.v2"
+)
+
+//Where the effective value of a setting came from, in increasing precedence
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+//configEnv the environment variable naming the config file
+const configEnv = "FAKE_SMS_CONFIG"
+
+//providersDirName the directory next to the config file holding provider definitions
+const providersDirName = "providers"
+
+//outputFormats the values accepted by the output setting, tsv is the format of earlier versions
+var outputFormats = []string{"table", "json", "jsonl", "csv", "yaml", "tsv"}
+
+//configKey a setting that can be given in the config file, as environment variable or as global flag
+type configKey struct {
+	//name the dotted path in the config file
+	name string
+	env  string
+	//flag the global flag, "" when the setting has none
+	flag string
+	//boolean lets the flag be given without a value, like a bool flag
+	boolean bool
+	usage   string
+	def     func() string
+	//check validates a value, nil accepts anything
+	check func(string) error
+}
+
+func constant(value string) func() string {
+	return func() string {
+		return value
+	}
+}
+
+func checkDuration(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return fmt.Errorf("expected a positive duration like 30s, got %q", value)
+	}
+	return nil
+}
+
+func checkCount(value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return fmt.Errorf("expected a number like 3, got %q", value)
+	}
+	return nil
+}
+
+func checkOneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, candidate := range allowed {
+			if value == candidate {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+}
+
+func checkProvider(value string) error {
+	return checkOneOf(fakesms.Providers()...)(value)
+}
+
+//checkDefaultProvider also accepts all, which lists the numbers of every provider
+func checkDefaultProvider(value string) error {
+	if value == fakesms.AllProviders {
+		return nil
+	}
+	return checkOneOf(append(fakesms.Providers(), fakesms.AllProviders)...)(value)
+}
+
+//proxyDirect the proxy setting that ignores HTTP_PROXY and HTTPS_PROXY
+const proxyDirect = "direct"
+
+func checkProxy(value string) error {
+	if value == "" || value == proxyDirect {
+		return nil
+	}
+	proxyURL, err := url.Parse(value)
+	if err != nil || proxyURL.Host == "" {
+		return fmt.Errorf("expected a proxy URL like http://127.0.0.1:3128 or socks5://127.0.0.1:9050, got %q", value)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q, expected http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+}
+
+//parseProviderProxies splits a list like "receive-smss=socks5://127.0.0.1:9050,other=direct"
+func parseProviderProxies(value string) (map[string]string, error) {
+	proxies := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separator := strings.Index(entry, "=")
+		if separator == -1 {
+			return nil, fmt.Errorf("expected PROVIDER=PROXY, got %q", entry)
+		}
+		provider, proxy := strings.TrimSpace(entry[:separator]), strings.TrimSpace(entry[separator+1:])
+		if err := checkProvider(provider); err != nil {
+			return nil, err
+		}
+		if err := checkProxy(proxy); err != nil {
+			return nil, fmt.Errorf("%s: %s", provider, err)
+		}
+		proxies[provider] = proxy
+	}
+	return proxies, nil
+}
+
+func checkProviderProxies(value string) error {
+	_, err := parseProviderProxies(value)
+	return err
+}
+
+//parseAggregateProviders splits a list like "receive-smss,other"
+func parseAggregateProviders(value string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func checkAggregateProviders(value string) error {
+	for _, name := range parseAggregateProviders(value) {
+		if err := checkProvider(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("expected true or false, got %q", value)
+	}
+	return nil
+}
+
+func checkFilter(value string) error {
+	_, err := regexp.Compile(value)
+	return err
+}
+
+//configKeys the settings in the order config show prints them
+var configKeys = []configKey{
+	{
+		name: "provider", env: "FAKE_SMS_PROVIDER", flag: "provider",
+		usage: "provider used when none is named, all lists the numbers of every provider", def: constant(fakesms.DefaultProvider), check: checkDefaultProvider,
+	},
+	{
+		name: "store", env: "FAKE_SMS_STORE", flag: "store",
+		usage: "DB backend, json or bolt", def: constant(fakesms.StoreJSON), check: checkOneOf(fakesms.StoreJSON, fakesms.StoreBolt),
+	},
+	{
+		name: "db_dir", env: "FAKE_SMS_DB_DIR", flag: "db-dir",
+		usage: "directory holding the DB", def: fakesms.DefaultDBDir,
+	},
+	{
+		name: "http.timeout", env: "FAKE_SMS_HTTP_TIMEOUT", flag: "http-timeout",
+		usage: "timeout of a single HTTP request", def: constant("30s"), check: checkDuration,
+	},
+	{
+		name: "http.retries", env: "FAKE_SMS_HTTP_RETRIES", flag: "http-retries",
+		usage: "retries of requests failing with network errors, 5xx or 429", def: constant("3"), check: checkCount,
+	},
+	{
+		name: "http.user_agent", env: "FAKE_SMS_USER_AGENT", flag: "user-agent",
+		usage: "User-Agent sent to providers", def: constant(fakesms.DefaultUserAgent),
+	},
+	{
+		name: "http.proxy", env: "FAKE_SMS_PROXY", flag: "proxy",
+		usage: "http, https, socks5 or socks5h proxy URL for provider requests, direct ignores the HTTPS_PROXY environment used when empty",
+		def:   constant(""), check: checkProxy,
+	},
+	{
+		name: "http.provider_proxies", env: "FAKE_SMS_PROVIDER_PROXIES", flag: "provider-proxies",
+		usage: "proxies of single providers, like receive-smss=socks5://127.0.0.1:9050", def: constant(""),
+		check: checkProviderProxies,
+	},
+	{
+		name: "debug_dump", env: "FAKE_SMS_DEBUG_DUMP", flag: "debug-dump",
+		usage: "directory the raw pages fetched from providers are saved in, none when empty", def: constant(""),
+	},
+	{
+		name: "aggregate.providers", env: "FAKE_SMS_AGGREGATE_PROVIDERS",
+		usage: "providers asked by --provider all, every provider but mock when empty", def: constant(""),
+		check: checkAggregateProviders,
+	},
+	{
+		name: "aggregate.timeout", env: "FAKE_SMS_AGGREGATE_TIMEOUT",
+		usage: "how long each provider asked by --provider all may take", def: constant("30s"), check: checkDuration,
+	},
+	{
+		name: "poll.interval", env: "FAKE_SMS_POLL_INTERVAL",
+		usage: "initial delay between polls of wait", def: constant("5s"), check: checkDuration,
+	},
+	{
+		name: "poll.max_interval", env: "FAKE_SMS_POLL_MAX_INTERVAL",
+		usage: "upper bound for the delay between polls of wait", def: constant("30s"), check: checkDuration,
+	},
+	{
+		name: "poll.timeout", env: "FAKE_SMS_POLL_TIMEOUT",
+		usage: "how long wait polls before giving up", def: constant("5m"), check: checkDuration,
+	},
+	{
+		name: "filter", env: "FAKE_SMS_FILTER",
+		usage: "default message filter", def: constant(""), check: checkFilter,
+	},
+	{
+		name: "output", env: "FAKE_SMS_OUTPUT", flag: "output",
+		usage: "output format of listings", def: constant("table"), check: checkOneOf(outputFormats...),
+	},
+	{
+		name: "export.enabled", env: "FAKE_SMS_EXPORT", flag: "export", boolean: true,
+		usage: "write a dump of the messages shown by the interactive menu", def: constant("true"), check: checkBool,
+	},
+	{
+		name: "export.dir", env: "FAKE_SMS_EXPORT_DIR", flag: "export-dir",
+		usage: "directory message dumps are written to", def: constant("."),
+	},
+	{
+		name: "export.name", env: "FAKE_SMS_EXPORT_NAME", flag: "export-name",
+		usage: "file name of message dumps", def: constant("{number}.{ext}"), check: checkExportName,
+	},
+	{
+		name: "export.format", env: "FAKE_SMS_EXPORT_FORMAT", flag: "export-format",
+		usage: "format of message dumps", def: constant("json"), check: checkOneOf(exportFormats...),
+	},
+	{
+		name: "export.mode", env: "FAKE_SMS_EXPORT_MODE", flag: "export-mode",
+		usage: "overwrite the dump, or append the new messages to it", def: constant(exportOverwrite),
+		check: checkOneOf(exportOverwrite, exportAppend),
+	},
+}
+
+//boolFlag a string flag that may be given without a value, which then means true
+type boolFlag struct {
+	value *string
+}
+
+func (b boolFlag) String() string {
+	if b.value == nil {
+		return ""
+	}
+	return *b.value
+}
+
+func (b boolFlag) Set(value string) error {
+	*b.value = value
+	return nil
+}
+
+//IsBoolFlag lets the flag package accept --flag without a value
+func (b boolFlag) IsBoolFlag() bool {
+	return true
+}
+
+//config The effective settings and where each of them came from
+type config struct {
+	//path the config file, it may not exist when it was not named explicitly
+	path       string
+	pathSource string
+	values     map[string]string
+	sources    map[string]string
+	//providers the names of the provider definitions loaded from providersDir
+	providers    []string
+	providersDir string
+}
+
+//defaultConfigPath the config file used when neither --config nor FAKE_SMS_CONFIG name one
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "fake-sms", "config.yaml")
+}
+
+//expandHome replaces a leading ~ with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+//flattenConfig turns the nested config file into dotted names
+func flattenConfig(prefix string, tree map[interface{}]interface{}, values map[string]string) error {
+	for rawKey, rawValue := range tree {
+		name := fmt.Sprint(rawKey)
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		switch value := rawValue.(type) {
+		case map[interface{}]interface{}:
+			if err := flattenConfig(name, value, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: expected a single value, got a list", name)
+		case nil:
+			values[name] = ""
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+//readConfigFile reads the settings of a config file, a missing file is only an error when required
+func readConfigFile(path string, required bool) (map[string]string, error) {
+	values := make(map[string]string)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree := make(map[interface{}]interface{})
+	if err = yaml.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err = flattenConfig("", tree, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	known := make(map[string]bool, len(configKeys))
+	for _, key := range configKeys {
+		known[key.name] = true
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("invalid config file %s: unknown setting %q", path, name)
+		}
+	}
+	return values, nil
+}
+
+//loadConfig parses the global flags in front of the command and resolves every setting.
+//Flags win over environment variables, which win over the config file and the defaults.
+//It returns the remaining arguments
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*config, []string, error) {
+	fs := flag.NewFlagSet("fake-sms", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	configFlag := fs.String("config", "", "config file, "+configEnv+" or "+defaultConfigPath()+" when empty")
+	flagValues := make(map[string]*string)
+	for _, key := range configKeys {
+		switch {
+		case key.boolean:
+			value := new(string)
+			fs.Var(boolFlag{value: value}, key.flag, key.usage)
+			flagValues[key.flag] = value
+		case key.flag != "":
+			flagValues[key.flag] = fs.String(key.flag, "", key.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, nil, err
+		}
+		return nil, nil, newUsageError("%s", err)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	cfg := &config{
+		path:       defaultConfigPath(),
+		pathSource: sourceDefault,
+		values:     make(map[string]string),
+		sources:    make(map[string]string),
+	}
+	if path, exists := lookupEnv(configEnv); exists && path != "" {
+		cfg.path, cfg.pathSource = path, sourceEnv
+	}
+	if set["config"] {
+		cfg.path, cfg.pathSource = *configFlag, sourceFlag
+	}
+	cfg.path = expandHome(cfg.path)
+
+	fileValues, err := readConfigFile(cfg.path, cfg.pathSource != sourceDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+	//before the settings are checked, they may name these providers
+	cfg.providersDir = filepath.Join(filepath.Dir(cfg.path), providersDirName)
+	if cfg.providers, err = fakesms.LoadDefinitions(cfg.providersDir); err != nil {
+		return nil, nil, err
+	}
+
+	for _, key := range configKeys {
+		value, source := key.def(), sourceDefault
+		if fileValue, exists := fileValues[key.name]; exists {
+			value, source = fileValue, sourceFile
+		}
+		if envValue, exists := lookupEnv(key.env); exists && envValue != "" {
+			value, source = envValue, sourceEnv
+		}
+		if key.flag != "" && set[key.flag] {
+			value, source = *flagValues[key.flag], sourceFlag
+		}
+
+		if key.check != nil {
+			if err = key.check(value); err != nil {
+				err = fmt.Errorf("invalid %s from %s: %s", key.name, cfg.describeSource(key, source), err)
+				if source == sourceFlag {
+					err = newUsageError("%s", err)
+				}
+				return nil, nil, err
+			}
+		}
+		cfg.values[key.name], cfg.sources[key.name] = value, source
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+//describeSource names the place a value came from, e.g. "env FAKE_SMS_STORE"
+func (c *config) describeSource(key configKey, source string) string {
+	switch source {
+	case sourceFile:
+		return "file " + c.path
+	case sourceEnv:
+		return "env " + key.env
+	case sourceFlag:
+		return "flag --" + key.flag
+	default:
+		return sourceDefault
+	}
+}
+
+//String Returns the value of a setting
+func (c *config) String(name string) string {
+	return c.values[name]
+}
+
+//Duration Returns the value of a duration setting, validated by loadConfig
+func (c *config) Duration(name string) time.Duration {
+	duration, _ := time.ParseDuration(c.values[name])
+	return duration
+}
+
+//Int Returns the value of a number setting, validated by loadConfig
+func (c *config) Int(name string) int {
+	value, _ := strconv.Atoi(c.values[name])
+	return value
+}
+
+//Bool Returns the value of a boolean setting, validated by loadConfig
+func (c *config) Bool(name string) bool {
+	value, _ := strconv.ParseBool(c.values[name])
+	return value
+}
+
+//Path Returns the value of a directory setting with ~ expanded
+func (c *config) Path(name string) string {
+	return expandHome(c.values[name])
+}
+
+//newClient builds the client the configuration describes
+func newClient(cfg *config) (*fakesms.Client, error) {
+	store, err := fakesms.OpenStore(cfg.String("store"), cfg.Path("db_dir"))
+	if err != nil {
+		return nil, err
+	}
+
+	options := []fakesms.Option{
+		fakesms.WithStore(store),
+		fakesms.WithStateDir(cfg.Path("db_dir")),
+		fakesms.WithHTTPClient(newHTTPClient(cfg, cfg.String("http.proxy"))),
+		fakesms.WithDefaultProvider(cfg.String("provider")),
+		fakesms.WithAggregateProviders(parseAggregateProviders(cfg.String("aggregate.providers"))...),
+		fakesms.WithAggregateTimeout(cfg.Duration("aggregate.timeout")),
+	}
+	if debugDir := cfg.Path("debug_dump"); debugDir != "" {
+		options = append(options, fakesms.WithDebugDump(debugDir))
+	}
+	proxies, _ := parseProviderProxies(cfg.String("http.provider_proxies"))
+	for provider, proxy := range proxies {
+		options = append(options, fakesms.WithProviderHTTPClient(provider, newHTTPClient(cfg, proxy)))
+	}
+	return fakesms.NewClient(options...), nil
+}
+
+//newHTTPClient builds a client sending its requests through proxy, see the http.proxy setting
+func newHTTPClient(cfg *config, proxy string) *http.Client {
+	//the clone keeps http.ProxyFromEnvironment, which honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch proxy {
+	case "":
+	case proxyDirect:
+		transport.Proxy = nil
+	default:
+		proxyURL, _ := url.Parse(proxy)
+		//the socks5 dialer of net/http already leaves name resolution to the proxy,
+		//which is what socks5h asks for
+		if proxyURL.Scheme == "socks5h" {
+			proxyURL.Scheme = "socks5"
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	retry := fakesms.DefaultRetryPolicy
+	retry.MaxRetries = cfg.Int("http.retries")
+	return &http.Client{
+		Transport: &fakesms.Transport{
+			Base:      transport,
+			UserAgent: cfg.String("http.user_agent"),
+			Timeout:   cfg.Duration("http.timeout"),
+			Retry:     retry,
+		},
+	}
+}
+
+//printConfig writes the effective settings as name, value and source
+func printConfig(w io.Writer, cfg *config) {
+	configSource := sourceDefault
+	switch cfg.pathSource {
+	case sourceEnv:
+		configSource = "env " + configEnv
+	case sourceFlag:
+		configSource = "flag --config"
+	}
+	if _, err := os.Stat(cfg.path); os.IsNotExist(err) {
+		configSource += ", missing"
+	}
+	fmt.Fprintf(w, "config\t%s\t%s\n", cfg.path, configSource)
+	providers := "none"
+	if len(cfg.providers) > 0 {
+		providers = strings.Join(cfg.providers, ", ")
+	}
+	fmt.Fprintf(w, "providers\t%s\t%s\n", cfg.providersDir, providers)
+
+	for _, key := range configKeys {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key.name, orDash(cfg.String(key.name)), cfg.describeSource(key, cfg.sources[key.name]))
+	}
+}
+
+func cmdConfig(cfg *config, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "show" {
+		return newUsageError("config requires the show sub-command")
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	printConfig(stdout, cfg)
+	return nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := tempDir(t)
+
+	path := filepath.Join(dir, "config.yaml")
+	file := "store: bolt\nhttp:\n  timeout: 10s\n  proxy: http://file:3128\npoll:\n  interval: 2s\n"
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"FAKE_SMS_HTTP_TIMEOUT": "20s",
+		"FAKE_SMS_PROXY":        "http://env:3128",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, exists := env[name]
+		return value, exists
+	}
+
+	cfg, rest, err := loadConfig([]string{"--config", path, "--proxy", "http://flag:3128", "numbers", "list"}, lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 2 || rest[0] != "numbers" {
+		t.Errorf("expected the command to remain, got %v", rest)
+	}
+
+	expected := map[string][2]string{
+		"store":         {"bolt", sourceFile},
+		"http.timeout":  {"20s", sourceEnv},
+		"http.proxy":    {"http://flag:3128", sourceFlag},
+		"poll.interval": {"2s", sourceFile},
+		"poll.timeout":  {"5m", sourceDefault},
+	}
+	for name, want := range expected {
+		if cfg.String(name) != want[0] || cfg.sources[name] != want[1] {
+			t.Errorf("%s = %q from %s, want %q from %s", name, cfg.String(name), cfg.sources[name], want[0], want[1])
+		}
+	}
+	if cfg.Duration("http.timeout") != 20*time.Second {
+		t.Errorf("unexpected http.timeout %s", cfg.Duration("http.timeout"))
+	}
+
+	if _, _, err = loadConfig([]string{"--config", filepath.Join(dir, "missing.yaml")}, lookupEnv); err == nil {
+		t.Error("expected an explicitly named missing config file to fail")
+	}
+	if err = ioutil.WriteFile(path, []byte("poll:\n  intervall: 2s\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = loadConfig([]string{"--config", path}, lookupEnv); err == nil {
+		t.Error("expected an unknown setting to fail")
+	}
+}
+
+func TestLoadConfigProviderAll(t *testing.T) {
+	dir := tempDir(t)
+
+	path := filepath.Join(dir, "config.yaml")
+	env := map[string]string{}
+	lookupEnv := func(name string) (string, bool) {
+		value, exists := env[name]
+		return value, exists
+	}
+	load := func(file string, args ...string) (*config, error) {
+		if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := loadConfig(append([]string{"--config", path}, args...), lookupEnv)
+		return cfg, err
+	}
+
+	//all is accepted wherever the provider is set, as in numbers available --provider all
+	for source, args := range map[string][]string{sourceFlag: {"--provider", "all"}, sourceEnv: nil, sourceFile: nil} {
+		file := ""
+		switch source {
+		case sourceEnv:
+			env["FAKE_SMS_PROVIDER"] = "all"
+		case sourceFile:
+			file = "provider: all\n"
+		}
+		cfg, err := load(file, args...)
+		if err != nil || cfg.String("provider") != "all" || cfg.sources["provider"] != source {
+			t.Errorf("%s: expected provider all to be accepted, got %v", source, err)
+		}
+		delete(env, "FAKE_SMS_PROVIDER")
+	}
+
+	if _, err := load("", "--provider", "nope"); err == nil {
+		t.Error("expected an unknown provider to be rejected")
+	}
+	if _, err := load("", "--provider-proxies", "all=direct"); err == nil {
+		t.Error("expected all to be rejected as the name of a proxied provider")
+	}
+}
+
+//socksStandIn a SOCKS5 proxy without authentication that records the addresses it connects to
+type socksStandIn struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	targets  []string
+}
+
+func newSocksStandIn(t *testing.T) *socksStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := &socksStandIn{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go proxy.serve(conn)
+		}
+	}()
+	return proxy
+}
+
+func (p *socksStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	//greeting: version, method count and methods, answered with "no authentication"
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil || header[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(reader, make([]byte, header[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	//request: version, CONNECT, reserved, address type, address and port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(reader, request); err != nil || request[1] != 1 {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		address := make([]byte, 4)
+		io.ReadFull(reader, address)
+		host = net.IP(address).String()
+	case 3:
+		length, _ := reader.ReadByte()
+		name := make([]byte, length)
+		io.ReadFull(reader, name)
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(reader, port); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1])))
+	p.mutex.Lock()
+	p.targets = append(p.targets, target)
+	p.mutex.Unlock()
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(upstream, reader)
+	io.Copy(conn, upstream)
+}
+
+func (p *socksStandIn) connections() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+func TestProxySettings(t *testing.T) {
+	dir := tempDir(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+	proxy := newSocksStandIn(t)
+	defer proxy.listener.Close()
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	lookupEnv := func(name string) (string, bool) {
+		return "", false
+	}
+	cfg, _, err := loadConfig([]string{
+		"--config", path, "--db-dir", dir,
+		"--proxy", "socks5h://" + proxy.listener.Addr().String(), "--provider-proxies", "receive-smss=direct",
+	}, lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := newClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(httpClient *http.Client) {
+		response, err := httpClient.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if string(body) != "ok" {
+			t.Fatalf("unexpected response %q", body)
+		}
+	}
+
+	get(client.HTTPClient)
+	if targets := proxy.connections(); len(targets) != 1 || targets[0] != server.Listener.Addr().String() {
+		t.Errorf("expected the request to go through the SOCKS5 proxy, got %v", targets)
+	}
+	get(client.HTTPClients["receive-smss"])
+	if targets := proxy.connections(); len(targets) != 1 {
+		t.Errorf("expected the provider proxy to bypass the SOCKS5 proxy, got %v", targets)
+	}
+
+	for _, value := range []string{"ftp://127.0.0.1:21", "receive-smss", "nosuchprovider=direct"} {
+		if _, _, err = loadConfig([]string{"--config", path, "--provider-proxies", value}, lookupEnv); err == nil {
+			t.Errorf("expected provider proxies %q to be rejected", value)
+		}
+	}
+	if _, _, err = loadConfig([]string{"--config", path, "--proxy", "ftp://127.0.0.1:21"}, lookupEnv); err == nil {
+		t.Error("expected an ftp proxy to be rejected")
+	}
+}
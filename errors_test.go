@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want int
+	}{
+		{fakesms.ErrWaitTimeout, exitTimeout},
+		{fmt.Errorf("%w: connection refused", fakesms.ErrProviderUnreachable), exitUnreachable},
+		{fmt.Errorf("%w: no number-boxes", fakesms.ErrLayoutChanged), exitLayoutChanged},
+		{fmt.Errorf("%w: +447700900123", fakesms.ErrNumberNotFound), exitNotFound},
+		{fmt.Errorf("%w: unexpected end of JSON input", fakesms.ErrDBCorrupt), exitDBCorrupt},
+		{fmt.Errorf("something else"), exitError},
+	} {
+		if code := exitCodeFor(test.err); code != test.want {
+			t.Errorf("%s: expected exit code %d, got %d", test.err, test.want, code)
+		}
+	}
+}
+
+func TestRunCommandReportsErrorKinds(t *testing.T) {
+	dir := tempDir(t)
+	//a site without the number boxes, and one that is down
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>Please come back later</body></html>")
+	}))
+	defer site.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	lookupEnv := func(name string) (string, bool) {
+		return "", false
+	}
+	cfg, _, err := loadConfig([]string{"--config", path, "--db-dir", dir}, lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(baseURL string, args ...string) int {
+		client := fakesms.NewClient(fakesms.WithStore(fakesms.NewJSONStore(dir)), fakesms.WithBaseURL(fakesms.DefaultProvider, baseURL))
+		var stdout, stderr bytes.Buffer
+		return runCommand(client, cfg, args, &stdout, &stderr)
+	}
+
+	if code := run(site.URL, "numbers", "available"); code != exitLayoutChanged {
+		t.Errorf("expected exit code %d for a changed page, got %d", exitLayoutChanged, code)
+	}
+	if code := run(down.URL, "numbers", "available"); code != exitUnreachable {
+		t.Errorf("expected exit code %d for an unreachable site, got %d", exitUnreachable, code)
+	}
+	if code := run(site.URL, "numbers", "rm", "+447700900123"); code != exitNotFound {
+		t.Errorf("expected exit code %d for a number that is not saved, got %d", exitNotFound, code)
+	}
+
+}
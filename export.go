@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+//Values of the export.mode setting
+const (
+	exportOverwrite = "overwrite"
+	exportAppend    = "append"
+)
+
+//exportFormats the values accepted by the export.format setting
+var exportFormats = []string{"json", "jsonl", "csv", "mbox"}
+
+//exportPlaceholders the placeholders of the export.name setting
+var exportPlaceholders = regexp.MustCompile(`\{[^{}]*\}`)
+
+//exportTimestampLayout how {timestamp} is written in file names
+const exportTimestampLayout = "20060102-150405"
+
+func checkExportName(name string) error {
+	for _, placeholder := range exportPlaceholders.FindAllString(name, -1) {
+		switch placeholder {
+		case "{number}", "{provider}", "{country}", "{timestamp}", "{ext}":
+		default:
+			return fmt.Errorf("unknown placeholder %s, expected {number}, {provider}, {country}, {timestamp} or {ext}", placeholder)
+		}
+	}
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("the file name is empty")
+	}
+	return nil
+}
+
+//exportOptions how message dumps are written, see the export settings
+type exportOptions struct {
+	enabled bool
+	dir     string
+	name    string
+	format  string
+	//appendNew adds the new messages to the dump instead of replacing it
+	appendNew bool
+}
+
+func exportOptionsFrom(cfg *config) exportOptions {
+	return exportOptions{
+		enabled:   cfg.Bool("export.enabled"),
+		dir:       cfg.Path("export.dir"),
+		name:      cfg.String("export.name"),
+		format:    cfg.String("export.format"),
+		appendNew: cfg.String("export.mode") == exportAppend,
+	}
+}
+
+//fileNameSafe keeps path separators and other troublesome characters out of file names
+var fileNameSafe = strings.NewReplacer("/", "_", `\`, "_", ":", "_", " ", "_")
+
+//exportPath fills in the placeholders of the file name
+func (o exportOptions) exportPath(number *fakesms.Number, now time.Time) string {
+	country := number.CountryCode
+	if country == "" {
+		country = "xx"
+	}
+	name := strings.NewReplacer(
+		"{number}", fileNameSafe.Replace(number.Number),
+		"{provider}", fileNameSafe.Replace(number.ProviderName()),
+		"{country}", strings.ToLower(country),
+		"{timestamp}", now.Format(exportTimestampLayout),
+		"{ext}", o.format,
+	).Replace(o.name)
+	return filepath.Join(o.dir, name)
+}
+
+//exportMessages writes the dump of a number and returns its path, "" when exports are disabled.
+//In append mode only the fresh messages among messages are added to an existing dump, so
+//messages left out by a filter are not appended either
+func exportMessages(options exportOptions, number *fakesms.Number, messages, fresh fakesms.Messages) (string, error) {
+	if !options.enabled {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(options.dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create export directory %s: %w", options.dir, err)
+	}
+
+	path := options.exportPath(number, time.Now())
+	_, err := os.Stat(path)
+	appending := options.appendNew && err == nil
+	if appending {
+		messages = freshAmong(messages, fresh)
+		if options.format == "json" {
+			//a JSON array cannot be appended to, the previous dump is read back instead
+			previous, err := readJSONExport(path)
+			if err != nil {
+				return "", err
+			}
+			messages, appending = append(previous, messages...), false
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appending {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open export file %s: %w", path, err)
+	}
+	//dumps of older versions were written with 0700
+	file.Chmod(0600)
+
+	writer := bufio.NewWriter(file)
+	err = writeExport(writer, options.format, messages, !appending)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save file %s: %w", path, err)
+	}
+	return path, nil
+}
+
+//freshAmong keeps the messages that are fresh, in the order of messages
+func freshAmong(messages, fresh fakesms.Messages) fakesms.Messages {
+	keys := make(map[string]bool, len(fresh))
+	for _, message := range fresh {
+		keys[message.Key] = true
+	}
+	kept := fakesms.Messages{}
+	for _, message := range messages {
+		if keys[message.Key] {
+			kept = append(kept, message)
+		}
+	}
+	return kept
+}
+
+func readJSONExport(path string) (fakesms.Messages, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read export file %s: %w", path, err)
+	}
+	previous := fakesms.Messages{}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return previous, nil
+	}
+	if err = json.Unmarshal(data, &previous); err != nil {
+		return nil, fmt.Errorf("cannot append to export file %s: %w", path, err)
+	}
+	return previous, nil
+}
+
+//writeExport writes the messages in format, header tells whether the file starts here
+func writeExport(w io.Writer, format string, messages fakesms.Messages, header bool) error {
+	switch format {
+	case "json":
+		return messagesListing(messages).write(w, "json")
+	case "jsonl":
+		return messagesListing(messages).write(w, "jsonl")
+	case "csv":
+		return messagesListing(messages).writeCSV(w, header)
+	case "mbox":
+		for idx := range messages {
+			if err := writeMbox(w, &messages[idx]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+//mboxFrom lines starting like this in a body are escaped, see mboxrd
+var mboxFrom = regexp.MustCompile(`(?m)^(>*From )`)
+
+//writeMbox writes a message as an mbox entry, so mail tools can browse the dump
+func writeMbox(w io.Writer, message *fakesms.Message) error {
+	receivedAt := message.ReceivedAt()
+	sender := strings.Join(strings.Fields(message.Originator), "_")
+	if sender == "" {
+		sender = "unknown"
+	}
+
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "From %s@fake-sms %s\n", sender, receivedAt.UTC().Format(time.ANSIC))
+	fmt.Fprintf(&entry, "From: %s\n", singleLine(message.Originator))
+	fmt.Fprintf(&entry, "Date: %s\n", receivedAt.Format(time.RFC1123Z))
+	fmt.Fprintf(&entry, "Subject: %s\n", singleLine(truncate(message.Body, 60)))
+	if message.Key != "" {
+		fmt.Fprintf(&entry, "X-Fake-SMS-Key: %s\n", message.Key)
+	}
+	if message.ExtractedCode != "" {
+		fmt.Fprintf(&entry, "X-Fake-SMS-Code: %s\n", message.ExtractedCode)
+	}
+	entry.WriteString("\n")
+	entry.WriteString(mboxFrom.ReplaceAllString(strings.TrimRight(message.Body, "\n"), ">$1"))
+	entry.WriteString("\n\n")
+
+	_, err := io.WriteString(w, entry.String())
+	return err
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+func TestExportMessages(t *testing.T) {
+	dir := tempDir(t)
+
+	number := &fakesms.Number{Number: "+4915735983768", CountryCode: "DE", Provider: "receive-smss"}
+	first := fakesms.Messages{{Key: "a", Originator: "Acme", Body: "code 111111", CreatedAt: time.Now()}}
+	second := fakesms.Messages{{Key: "b", Originator: "Acme", Body: "From the team\ncode 222222", CreatedAt: time.Now()}}
+
+	options := exportOptions{
+		enabled: true, dir: filepath.Join(dir, "dumps"), name: "{provider}-{country}-{number}.{ext}",
+		format: "json", appendNew: true,
+	}
+	path, err := exportMessages(options, number, first, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "receive-smss-de-+4915735983768.json" {
+		t.Errorf("unexpected file name %s", path)
+	}
+	if _, err = exportMessages(options, number, append(first, second...), second); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	data, _ := ioutil.ReadFile(path)
+	var dumped fakesms.Messages
+	if err = json.Unmarshal(data, &dumped); err != nil || len(dumped) != 2 {
+		t.Fatalf("expected both messages in the json dump, got %s (%v)", data, err)
+	}
+
+	//csv and mbox dumps are appended to without repeating the header
+	for _, format := range []string{"csv", "mbox"} {
+		options.format = format
+		exportMessages(options, number, first, first)
+		path, err = exportMessages(options, number, append(first, second...), second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ = ioutil.ReadFile(path)
+		switch format {
+		case "csv":
+			if strings.Count(string(data), "key,sender") != 1 || !strings.Contains(string(data), "222222") {
+				t.Errorf("unexpected csv dump %q", data)
+			}
+		case "mbox":
+			if strings.Count(string(data), "\nFrom Acme@fake-sms ") != 1 || !strings.Contains(string(data), "\n>From the team") {
+				t.Errorf("unexpected mbox dump %q", data)
+			}
+		}
+	}
+
+	options.enabled = false
+	if path, err = exportMessages(options, number, first, first); path != "" || err != nil {
+		t.Errorf("expected no dump when disabled, got %q %v", path, err)
+	}
+}
+
+func TestExportAppendsOnlyFilteredMessages(t *testing.T) {
+	dir := tempDir(t)
+
+	number := &fakesms.Number{Number: "+4915735983768", Provider: "receive-smss"}
+	options := exportOptions{enabled: true, dir: dir, name: "{number}.{ext}", appendNew: true}
+	old := fakesms.Message{Key: "a", Originator: "Acme", Body: "code 111111", CreatedAt: time.Now()}
+	code := fakesms.Message{Key: "b", Originator: "Acme", Body: "code 222222", CreatedAt: time.Now()}
+	advert := fakesms.Message{Key: "c", Originator: "Shop", Body: "50% off today", CreatedAt: time.Now()}
+
+	for _, format := range []string{"json", "jsonl", "csv"} {
+		options.format = format
+		if _, err := exportMessages(options, number, fakesms.Messages{old}, fakesms.Messages{old}); err != nil {
+			t.Fatal(err)
+		}
+		//both are new, the filter only let the code through
+		filtered, _ := fakesms.FilterMessages("code", fakesms.Messages{advert, code, old})
+		path, err := exportMessages(options, number, filtered, fakesms.Messages{advert, code})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadFile(path)
+		if strings.Contains(string(data), "50% off") || !strings.Contains(string(data), "222222") {
+			t.Errorf("%s: expected only the filtered new message to be appended, got %q", format, data)
+		}
+	}
+}
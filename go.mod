@@ -3,6 +3,9 @@ module github.com/Narasimha1997/fake-sms
 go 1.15
 
 require (
-	github.com/anaskhan96/soup v1.2.4
+	github.com/andybalholm/cascadia v1.2.0
 	github.com/manifoldco/promptui v0.8.0
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -1,351 +1,314 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
-	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
 	"github.com/manifoldco/promptui"
 )
 
-//Number A struct that represents a new number to be addeded
-type Number struct {
-	Country   string `json:"country"`
-	Number    string `json:"number"`
-	CreatedAt string `json:"created_at"`
-}
-
-//Message a struct which represents the message
-type Message struct {
-	Body       string `json:"body"`
-	CreatedAt  string `json:"created_at"`
-	Originator string `json:"originator"`
-}
-
-//Numbers A list of Number type
-type Numbers []Number
-
-//Messages A list of Message type
-type Messages []Message
-
 func exitFatal(err error) {
 	log.Fatal(err)
 }
 
-//DB The database functions group
-type DB struct {
-}
-
-func (d *DB) getDBPath() string {
-	/*
-		Look for the path to be specified in ENV FAKE_SMS_DB_DIR,
-		if not, use default $HOME as the path to create DB.
-		The DB will be created at <db_dir>/.fake-sms/db.json
-		If the DB does not exist, it will be created and will be
-		initialized to an empty array []
-	*/
-
-	dbPath, exists := os.LookupEnv("FAKE_SMS_DB_DIR")
-	if !exists {
-		dbPath = os.Getenv("HOME")
-		dbPath = filepath.Join(dbPath, ".fake-sms")
+func numbersToList(numbers fakesms.Numbers) []string {
+	listOfNumbers := make([]string, len(numbers))
+	for idx, number := range numbers {
+		listOfNumbers[idx] = fmt.Sprintf("%s (%s)", number.Number, number.Country)
 	}
+	return listOfNumbers
+}
 
-	_, err := os.Stat(dbPath)
-	if os.IsNotExist(err) {
-		err = os.MkdirAll(dbPath, 0700)
-		if err != nil {
-			log.Fatalf("Failed to create DB directory at %s\n", dbPath)
+//fuzzyMatch reports whether the letters of input appear in text in the same order, ignoring
+//case and spaces, so "grmny" finds "Germany"
+func fuzzyMatch(input, text string) bool {
+	text = strings.ToLower(text)
+	for _, r := range strings.ToLower(input) {
+		if unicode.IsSpace(r) {
+			continue
 		}
-	}
-
-	dbPath = filepath.Join(dbPath, "db.json")
-	_, err = os.Stat(dbPath)
-	if os.IsNotExist(err) {
-		emptyArray := []byte("[\n]\n")
-		err = ioutil.WriteFile(dbPath, emptyArray, 0700)
-		if err != nil {
-			log.Fatalf("Faild to create DB file at %s\n", dbPath)
+		idx := strings.IndexRune(text, r)
+		if idx == -1 {
+			return false
 		}
+		text = text[idx+utf8.RuneLen(r):]
 	}
-
-	return dbPath
+	return true
 }
 
-func (d *DB) addToDB(number *Number) {
-	dbPath := d.getDBPath()
-	//read and serialize it to numbers
-	data, err := ioutil.ReadFile(dbPath)
-	if err != nil {
-		log.Fatalf("Failed to read DB file at %s\n", dbPath)
-	}
-
-	//unmarshall the db to Numbers type
-	numbers := Numbers{}
-	err = json.Unmarshal(data, &numbers)
-	if err != nil {
-		log.Fatalf("Failed to de-serialize DB file %s\n", dbPath)
+//numberSearcher lets promptui search the numbers by number, country and country code
+func numberSearcher(numbers fakesms.Numbers) func(input string, index int) bool {
+	return func(input string, index int) bool {
+		number := &numbers[index]
+		return fuzzyMatch(input, number.Number) ||
+			fuzzyMatch(input, number.Country) ||
+			strings.EqualFold(strings.TrimSpace(input), number.CountryCode)
 	}
+}
 
-	numbers = append(numbers, *number)
-
-	//write it back to the db
-	data, err = json.Marshal(numbers)
-	if err != nil {
-		log.Fatalf("Failed to serialize DB file %s\n", dbPath)
+//selectCountry lets the user narrow the numbers down to one country, all numbers when
+//there is only one country or "All countries" is picked
+func selectCountry(numbers fakesms.Numbers) (fakesms.Numbers, error) {
+	countries := numbers.Countries()
+	if len(countries) < 2 {
+		return numbers, nil
 	}
 
-	err = ioutil.WriteFile(dbPath, data, 0700)
-	if err != nil {
-		log.Fatalf("Failed to save DB file %s\n", dbPath)
+	items := append([]string{"All countries"}, countries...)
+	prompt := promptui.Select{
+		Label: "Which country do you want a number from? Press / to search",
+		Items: items,
+		Searcher: func(input string, index int) bool {
+			return index == 0 || fuzzyMatch(input, items[index]) ||
+				strings.EqualFold(strings.TrimSpace(input), fakesms.CountryCode(items[index]))
+		},
 	}
-}
 
-func (d *DB) getFromDB() *Numbers {
-	dbPath := d.getDBPath()
-	//read and serialize it to numbers
-	data, err := ioutil.ReadFile(dbPath)
+	idx, _, err := prompt.Run()
 	if err != nil {
-		log.Fatalf("Failed to read DB file at %s\n", dbPath)
+		return nil, err
 	}
-
-	//unmarshall the db to Numbers type
-	numbers := Numbers{}
-	err = json.Unmarshal(data, &numbers)
-	if err != nil {
-		log.Fatalf("Failed to de-serialize DB file %s\n", dbPath)
+	if idx <= 0 {
+		return numbers, nil
 	}
-
-	return &numbers
+	return numbers.ByCountry(countries[idx-1]), nil
 }
 
-func (d *DB) deleteFromDB(idx *int) {
-	dbPath := d.getDBPath()
-	//read and serialize it to numbers
-	data, err := ioutil.ReadFile(dbPath)
-	if err != nil {
-		log.Fatalf("Failed to read DB file at %s\n", dbPath)
+func displayInitParameters() int {
+	prompt := promptui.Select{
+		Label: "What you want to do?",
+		Items: []string{"Add a new number", "List my numbers", "Remove a number", "Get my messages", "Show stored messages", "Exit"},
 	}
 
-	//unmarshall the db to Numbers type
-	numbers := Numbers{}
-	err = json.Unmarshal(data, &numbers)
+	idx, _, err := prompt.Run()
 	if err != nil {
-		log.Fatalf("Failed to de-serialize DB file %s\n", dbPath)
-	}
-
-	//delete by index
-	if *idx > len(numbers)-1 {
-		log.Fatalln("Number does not exist to be deleted from DB")
+		exitFatal(err)
 	}
 
-	numbers = append(numbers[:*idx], numbers[*idx+1:]...)
-	//serialize it back
-	data, err = json.Marshal(numbers)
-	if err != nil {
-		log.Fatalf("Failed to serialize DB file %s\n", dbPath)
-	}
+	//Return the index of the parameter selected
+	return idx
+}
 
-	err = ioutil.WriteFile(dbPath, data, 0700)
-	if err != nil {
-		log.Fatalf("Failed to save DB file %s\n", dbPath)
+//selectProvider lets the user pick a provider, starting at the configured one
+func selectProvider(cfg *config) (string, error) {
+	names := fakesms.Providers()
+	if len(names) == 1 {
+		return names[0], nil
 	}
-}
+	names = append(names, fakesms.AllProviders)
 
-func numbersToList(numbers *Numbers) *[]string {
-	listOfNumbers := make([]string, len(*numbers))
-	for idx, number := range *numbers {
-		listOfNumbers[idx] = fmt.Sprintf("%s (%s)", number.Number, number.Country)
+	cursor := 0
+	for idx, name := range names {
+		if name == cfg.String("provider") {
+			cursor = idx
+		}
 	}
-	return &listOfNumbers
-}
 
-func displayInitParameters() int {
 	prompt := promptui.Select{
-		Label: "What you want to do?",
-		Items: []string{"Add a new number", "List my numbers", "Remove a number", "Get my messages", "Exit"},
+		Label:     "Which provider do you want to use?",
+		Items:     names,
+		CursorPos: cursor,
 	}
 
 	idx, _, err := prompt.Run()
 	if err != nil {
-		exitFatal(err)
+		return "", err
 	}
 
-	//Return the index of the parameter selected
-	return idx
-}
-
-func getAvailNumbers() *Numbers {
-
-	numArray := ScrapeAvailableNumbers()
-	numbers := Numbers(numArray)
-	return &numbers
+	return names[idx], nil
 }
 
-func registerNumber() {
-	numbers := getAvailNumbers()
-
-	if len(*numbers) == 0 {
-		fmt.Println("No new numbers available right now")
-	} else {
-		numberList := numbersToList(numbers)
-		//display numbers
-		prompt := promptui.Select{
-			Label: "These are the available numbers, choose any one of them",
-			Items: *numberList,
-		}
+func registerNumber(client *fakesms.Client, cfg *config) error {
+	providerName, err := selectProvider(cfg)
+	if err != nil {
+		return err
+	}
 
-		idx, _, err := prompt.Run()
+	var numbers fakesms.Numbers
+	if providerName == fakesms.AllProviders {
+		var failures []fakesms.ProviderFailure
+		numbers, failures, err = client.AvailableNumbersFromAll(context.Background())
 		if err != nil {
-			exitFatal(err)
+			return err
 		}
-
-		if idx == -1 {
-			fmt.Println("Nothing selected")
-		} else {
-			//new number selected, save it to the database file
-			selectedNumber := &(*numbers)[idx]
-			fmt.Printf("Selected %s, saving to database\n", selectedNumber)
-			db := DB{}
-			db.addToDB(selectedNumber)
+		for _, failure := range failures {
+			fmt.Printf("Skipped %s\n", failure)
 		}
+	} else if numbers, err = client.AvailableNumbers(context.Background(), providerName); err != nil {
+		return err
 	}
-}
-
-func listNumbers() {
-	db := DB{}
-	numbers := db.getFromDB()
 
-	fmt.Println("Country\t\tNumber\t\tCreated At")
-	fmt.Println("=======================================================================")
-	for _, number := range *numbers {
-		fmt.Printf(
-			"%s\t\t%s\t\t%s\n",
-			number.Country, number.Number, number.CreatedAt,
-		)
+	if len(numbers) == 0 {
+		fmt.Println("No new numbers available right now")
+		return nil
 	}
-}
 
-func removeNumbers() {
-	db := DB{}
-	numbers := db.getFromDB()
-
-	numberList := numbersToList(numbers)
-
-	if len(*numberList) == 0 {
-		log.Fatalln("No numbers saved to delete")
+	if numbers, err = selectCountry(numbers); err != nil {
+		return err
 	}
 
-	//display the list
+	//display numbers
 	prompt := promptui.Select{
-		Label: "These are the available numbers, choose any one of them",
-		Items: *numberList,
+		Label:    "These are the available numbers, choose any one of them. Press / to search",
+		Items:    numbersToList(numbers),
+		Searcher: numberSearcher(numbers),
 	}
 
 	idx, _, err := prompt.Run()
 	if err != nil {
-		exitFatal(err)
+		return err
 	}
 
 	if idx == -1 {
 		fmt.Println("Nothing selected")
-	} else {
-		//new number selected, save it to the database file
-		selectedNumber := &(*numbers)[idx]
-		fmt.Printf("Selected %s, removing from database\n", selectedNumber)
-		db.deleteFromDB(&idx)
+		return nil
 	}
+
+	//new number selected, save it to the database file
+	selectedNumber := &numbers[idx]
+	fmt.Printf("Selected %s, saving to database\n", selectedNumber)
+	return client.Store.AddNumber(selectedNumber)
 }
 
-func messagePatternCheck(pattern *string, messages *Messages) Messages {
-	r, err := regexp.Compile(*pattern)
+func listNumbers(client *fakesms.Client) error {
+	numbers, err := client.Store.ListNumbers()
 	if err != nil {
-		log.Fatalln("Invalid regular expression provided")
-	}
-
-	filteredMessages := make([]Message, 0)
-	for _, message := range *messages {
-		//check match
-		isMatch := r.Match([]byte(message.Body))
-		if isMatch {
-			filteredMessages = append(filteredMessages, message)
-		}
+		return err
 	}
 
-	return Messages(filteredMessages)
+	return numbersListing(numbers).write(os.Stdout, "table")
 }
 
-func checkMessages(enableFilter bool) {
-
-	db := DB{}
-	numbers := db.getFromDB()
-
-	numberList := numbersToList(numbers)
+//selectSavedNumber lets the user pick one of the saved numbers, nil when there is none
+func selectSavedNumber(client *fakesms.Client) (*fakesms.Number, error) {
+	numbers, err := client.Store.ListNumbers()
+	if err != nil {
+		return nil, err
+	}
 
-	if len(*numberList) == 0 {
-		log.Fatalln("No numbers saved to delete")
+	if len(numbers) == 0 {
+		fmt.Println("No numbers saved yet")
+		return nil, nil
 	}
 
 	//display the list
 	prompt := promptui.Select{
-		Label: "These are the available numbers, choose any one of them",
-		Items: *numberList,
+		Label:    "These are the available numbers, choose any one of them. Press / to search",
+		Items:    numbersToList(numbers),
+		Searcher: numberSearcher(numbers),
 	}
 
 	idx, _, err := prompt.Run()
 	if err != nil {
-		exitFatal(err)
+		return nil, err
 	}
 
 	if idx == -1 {
 		fmt.Println("Nothing selected")
-	} else {
-		//new number selected, save it to the database file
-		selectedNumber := &(*numbers)[idx]
-		fmt.Printf("Selected %s, fetching messages\n", selectedNumber)
+		return nil, nil
+	}
+	return &numbers[idx], nil
+}
 
-		messagesArray := ScrapeMessagesForNumber(selectedNumber.Number)
+func removeNumbers(client *fakesms.Client) error {
+	selectedNumber, err := selectSavedNumber(client)
+	if err != nil || selectedNumber == nil {
+		return err
+	}
 
-		//check message
-		messages := Messages(messagesArray)
+	fmt.Printf("Selected %s, removing from database\n", selectedNumber)
+	return client.Store.RemoveNumber(selectedNumber.Number)
+}
 
-		//run filter if enabled:
-		if enableFilter {
-			fmt.Println("Enter the filter regular expression:")
-			userFilterInput := ""
+func checkMessages(client *fakesms.Client, cfg *config, enableFilter bool) error {
+	selectedNumber, err := selectSavedNumber(client)
+	if err != nil || selectedNumber == nil {
+		return err
+	}
 
-			fmt.Scanln(&userFilterInput)
-			if userFilterInput == "" {
-				userFilterInput = `.*`
-			}
+	fmt.Printf("Selected %s, fetching messages\n", selectedNumber)
 
-			//run the filter
-			messages = messagePatternCheck(&userFilterInput, &messages)
-		}
+	messages, fresh, err := client.Fetch(context.Background(), selectedNumber)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d new message(s)\n", len(fresh))
 
-		fmt.Println("===========================================")
-		for _, message := range messages {
-			fmt.Printf("Sender : %s, at : %s\n", message.Originator, message.CreatedAt)
-			fmt.Printf("Body : %s\n", message.Body)
-			fmt.Println("===========================================")
+	//run filter if enabled:
+	if enableFilter {
+		defaultFilter := cfg.String("filter")
+		if defaultFilter == "" {
+			defaultFilter = `.*`
 		}
+		fmt.Printf("Enter the filter regular expression (%s):\n", defaultFilter)
+		userFilterInput := ""
 
-		indentedData, _ := json.MarshalIndent(messages, "", "\t")
+		fmt.Scanln(&userFilterInput)
+		if userFilterInput == "" {
+			userFilterInput = defaultFilter
+		}
 
-		//save the body as json
-		fileName := fmt.Sprintf("%s.json", selectedNumber.Number)
-		err = ioutil.WriteFile(fileName, indentedData, 0700)
+		//run the filter
+		messages, err = fakesms.FilterMessages(userFilterInput, messages)
 		if err != nil {
-			log.Fatalf("Failed to save file %s\n", fileName)
+			return err
 		}
 	}
+
+	if err = displayMessages(messages); err != nil {
+		return err
+	}
+	if _, err = client.MarkRead(selectedNumber.Number, true); err != nil {
+		return err
+	}
+
+	//save a dump of the messages, see the export settings
+	path, err := exportMessages(exportOptionsFrom(cfg), selectedNumber, messages, fresh)
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		fmt.Printf("Saved the messages to %s\n", path)
+	}
+	return nil
+}
+
+func displayMessages(messages fakesms.Messages) error {
+	return messagesListing(messages).write(os.Stdout, "table")
+}
+
+//showHistory lists the stored messages of a saved number without going online
+func showHistory(client *fakesms.Client) error {
+	selectedNumber, err := selectSavedNumber(client)
+	if err != nil || selectedNumber == nil {
+		return err
+	}
+
+	messages, err := client.History(selectedNumber.Number)
+	if err != nil {
+		return err
+	}
+
+	if len(messages) == 0 {
+		fmt.Println("No messages stored for this number yet")
+		return nil
+	}
+
+	if err = displayMessages(messages); err != nil {
+		return err
+	}
+	_, err = client.MarkRead(selectedNumber.Number, true)
+	return err
 }
 
-func shouldIncludeFilter() bool {
+func shouldIncludeFilter() (bool, error) {
 	prompt := promptui.Select{
 		Label: "Do you want to filter the messages?",
 		Items: []string{"Yes", "No"},
@@ -353,43 +316,84 @@ func shouldIncludeFilter() bool {
 
 	idx, _, err := prompt.Run()
 	if err != nil {
-		log.Fatalln("Failed to render prompt")
+		return false, err
 	}
 
-	if idx == 0 {
-		return true
-	}
+	return idx == 0, nil
+}
 
-	return false
+//reportError shows a failed menu action, the user stays in the menu
+func reportError(err error) {
+	switch {
+	case errors.Is(err, fakesms.ErrProviderUnreachable):
+		fmt.Printf("Could not reach the provider, check your connection and try again (%s)\n", err)
+	case errors.Is(err, fakesms.ErrBlocked):
+		fmt.Printf("The provider blocked the request, try again later or through another network (%s)\n", err)
+	case errors.Is(err, fakesms.ErrLayoutChanged):
+		fmt.Printf("The provider page changed and could not be read, please report this with the pages saved by --debug-dump (%s)\n", err)
+	case errors.Is(err, fakesms.ErrNumberNotFound):
+		fmt.Printf("The number could not be found (%s)\n", err)
+	case errors.Is(err, fakesms.ErrDBCorrupt):
+		fmt.Printf("The local DB is damaged, fix or remove it (%s)\n", err)
+	default:
+		fmt.Printf("Error: %s\n", err)
+	}
 }
 
 func main() {
 
-	ScrapeAvailableNumbers()
+	cfg, args, err := loadConfig(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		os.Exit(reportCommandError(err, os.Stdout, os.Stderr))
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		os.Exit(reportCommandError(err, os.Stdout, os.Stderr))
+	}
+
+	if len(args) > 0 {
+		os.Exit(runCommand(client, cfg, args, os.Stdout, os.Stderr))
+	}
 
 	for true {
 		idx := displayInitParameters()
 
+		var err error
 		switch idx {
 		case 0:
-			registerNumber()
+			err = registerNumber(client, cfg)
 			break
 		case 1:
-			listNumbers()
+			err = listNumbers(client)
 			break
 		case 2:
-			removeNumbers()
+			err = removeNumbers(client)
 			break
 		case 3:
 			//check if filter needs to be enabled
-			includeFilter := shouldIncludeFilter()
-			checkMessages(includeFilter)
+			var includeFilter bool
+			includeFilter, err = shouldIncludeFilter()
+			if err == nil {
+				err = checkMessages(client, cfg, includeFilter)
+			}
 			break
 		case 4:
+			err = showHistory(client)
+			break
+		case 5:
 			fmt.Println("Bye!")
 			os.Exit(0)
 		default:
 			log.Fatalf("Option %d yet to be implemented\n", idx)
 		}
+
+		if err == promptui.ErrInterrupt || err == promptui.ErrEOF {
+			fmt.Println("Bye!")
+			os.Exit(0)
+		}
+		if err != nil {
+			reportError(err)
+		}
 	}
 }
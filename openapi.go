@@ -0,0 +1,356 @@
+package main
+
+//openAPISpec describes the API served by `fake-sms serve`
+const openAPISpec = `{
+	"openapi": "3.0.3",
+	"info": {
+		"title": "fake-sms",
+		"description": "Temporary phone numbers from public receive-SMS sites and the messages they receive.",
+		"version": "1.0.0"
+	},
+	"paths": {
+		"/api/providers": {
+			"get": {
+				"summary": "List the registered providers",
+				"responses": {
+					"200": {
+						"description": "Provider names",
+						"content": {"application/json": {"schema": {"type": "array", "items": {"type": "string"}}}}
+					}
+				}
+			}
+		},
+		"/api/numbers/available": {
+			"get": {
+				"summary": "List the numbers currently offered by a provider",
+				"description": "The provider all merges the numbers of every aggregated provider that answers, see /api/numbers/available/all.",
+				"parameters": [
+					{"$ref": "#/components/parameters/provider"},
+					{"name": "country", "in": "query", "description": "Only list numbers of this country, an ISO code like DE or a name", "schema": {"type": "string"}}
+				],
+				"responses": {
+					"200": {
+						"description": "Available numbers",
+						"content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Number"}}}}
+					},
+					"400": {"$ref": "#/components/responses/Error"},
+					"502": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/available/all": {
+			"get": {
+				"summary": "List the numbers offered by every aggregated provider",
+				"description": "The providers are asked concurrently, each within aggregate.timeout. A number offered twice is listed once, with the first provider of aggregate.providers. Providers that fail are listed in failures, the request only fails when every provider does.",
+				"parameters": [
+					{"name": "country", "in": "query", "description": "Only list numbers of this country, an ISO code like DE or a name", "schema": {"type": "string"}}
+				],
+				"responses": {
+					"200": {
+						"description": "Available numbers and the providers that failed",
+						"content": {
+							"application/json": {
+								"schema": {
+									"type": "object",
+									"properties": {
+										"numbers": {"type": "array", "items": {"$ref": "#/components/schemas/Number"}},
+										"failures": {
+											"type": "array",
+											"items": {
+												"type": "object",
+												"properties": {
+													"provider": {"type": "string"},
+													"error": {"type": "string"},
+													"kind": {"type": "string", "description": "One of the kinds of Error"}
+												}
+											}
+										}
+									}
+								}
+							}
+						}
+					},
+					"502": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers": {
+			"get": {
+				"summary": "List the saved numbers",
+				"responses": {
+					"200": {
+						"description": "Saved numbers",
+						"content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Number"}}}}
+					},
+					"500": {"$ref": "#/components/responses/Error"}
+				}
+			},
+			"post": {
+				"summary": "Save one of the available numbers",
+				"description": "Either number or country is required. With only a country the first unused number of that country is saved.",
+				"requestBody": {
+					"required": true,
+					"content": {
+						"application/json": {
+							"schema": {
+								"type": "object",
+								"properties": {
+									"number": {"type": "string", "example": "+4915735983768"},
+									"provider": {"type": "string", "example": "receive-smss"},
+									"country": {"type": "string", "example": "DE"}
+								}
+							}
+						}
+					}
+				},
+				"responses": {
+					"201": {
+						"description": "The saved number",
+						"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Number"}}}
+					},
+					"400": {"$ref": "#/components/responses/Error"},
+					"404": {"$ref": "#/components/responses/Error"},
+					"409": {"$ref": "#/components/responses/Error"},
+					"502": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/{number}": {
+			"parameters": [{"$ref": "#/components/parameters/number"}],
+			"delete": {
+				"summary": "Remove a saved number",
+				"responses": {
+					"204": {"description": "Removed"},
+					"404": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/{number}/messages": {
+			"parameters": [{"$ref": "#/components/parameters/number"}],
+			"get": {
+				"summary": "Fetch the messages received by a number",
+				"description": "The fetched messages are stored, see the history endpoint.",
+				"parameters": [
+					{"$ref": "#/components/parameters/provider"},
+					{"$ref": "#/components/parameters/filter"},
+					{"name": "new", "in": "query", "description": "Only return messages that were not fetched before", "schema": {"type": "boolean"}},
+					{"$ref": "#/components/parameters/since"}
+				],
+				"responses": {
+					"200": {
+						"description": "Messages, newest first",
+						"content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Message"}}}}
+					},
+					"400": {"$ref": "#/components/responses/Error"},
+					"404": {"$ref": "#/components/responses/Error"},
+					"502": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/{number}/history": {
+			"parameters": [{"$ref": "#/components/parameters/number"}],
+			"get": {
+				"summary": "List the stored messages of a number without contacting the provider",
+				"parameters": [
+					{"$ref": "#/components/parameters/filter"},
+					{"name": "unread", "in": "query", "description": "Only return messages not marked as read", "schema": {"type": "boolean"}},
+					{"$ref": "#/components/parameters/since"}
+				],
+				"responses": {
+					"200": {
+						"description": "Stored messages, newest first",
+						"content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Message"}}}}
+					},
+					"400": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/{number}/read": {
+			"parameters": [{"$ref": "#/components/parameters/number"}],
+			"post": {
+				"summary": "Mark stored messages as read",
+				"requestBody": {"$ref": "#/components/requestBodies/MessageKeys"},
+				"responses": {
+					"200": {"$ref": "#/components/responses/Changed"},
+					"400": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/{number}/unread": {
+			"parameters": [{"$ref": "#/components/parameters/number"}],
+			"post": {
+				"summary": "Mark stored messages as unread",
+				"requestBody": {"$ref": "#/components/requestBodies/MessageKeys"},
+				"responses": {
+					"200": {"$ref": "#/components/responses/Changed"},
+					"400": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/numbers/{number}/wait": {
+			"parameters": [{"$ref": "#/components/parameters/number"}],
+			"get": {
+				"summary": "Block until a new message matching the filter arrives",
+				"description": "Only messages that arrive after the request started are considered. The code is the first capture group of the filter, or the detected one-time code when the filter has no groups.",
+				"parameters": [
+					{"$ref": "#/components/parameters/provider"},
+					{"$ref": "#/components/parameters/filter"},
+					{"name": "timeout", "in": "query", "description": "Go duration, at most 10m", "schema": {"type": "string", "default": "60s"}},
+					{"name": "interval", "in": "query", "description": "Initial poll interval as a Go duration, the poll.interval setting by default. The interval grows up to poll.max_interval", "schema": {"type": "string", "example": "5s"}}
+				],
+				"responses": {
+					"200": {
+						"description": "The matching message",
+						"content": {
+							"application/json": {
+								"schema": {
+									"type": "object",
+									"properties": {
+										"code": {"type": "string"},
+										"message": {"$ref": "#/components/schemas/Message"}
+									}
+								}
+							}
+						}
+					},
+					"400": {"$ref": "#/components/responses/Error"},
+					"408": {"$ref": "#/components/responses/Error"},
+					"502": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/health": {
+			"post": {
+				"summary": "Check whether saved numbers are active, idle or dead",
+				"description": "Fetches the messages of each number and records the status in the DB. Numbers whose page is gone are dead, numbers without a message within idle_after are idle.",
+				"parameters": [
+					{"name": "number", "in": "query", "description": "Number to check, repeatable, all saved numbers when missing", "schema": {"type": "array", "items": {"type": "string"}}, "explode": true},
+					{"name": "idle_after", "in": "query", "description": "Go duration without messages after which a number is idle", "schema": {"type": "string", "default": "72h"}},
+					{"name": "rotate", "in": "query", "description": "Replace dead numbers with unused ones of the same provider, preferring the same country", "schema": {"type": "boolean"}}
+				],
+				"responses": {
+					"200": {
+						"description": "One report per checked number",
+						"content": {
+							"application/json": {
+								"schema": {
+									"type": "array",
+									"items": {
+										"type": "object",
+										"properties": {
+											"number": {"$ref": "#/components/schemas/Number"},
+											"replacement": {"$ref": "#/components/schemas/Number"}
+										}
+									}
+								}
+							}
+						}
+					},
+					"400": {"$ref": "#/components/responses/Error"},
+					"404": {"$ref": "#/components/responses/Error"},
+					"502": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		},
+		"/api/mock/messages": {
+			"post": {
+				"summary": "Deliver a message to a number of the mock provider",
+				"description": "The message is returned by the messages and wait endpoints of the number like one received by a real provider. Numbers messages are sent to are offered by the mock provider from then on.",
+				"requestBody": {
+					"required": true,
+					"content": {
+						"application/json": {
+							"schema": {
+								"type": "object",
+								"required": ["to", "body"],
+								"properties": {
+									"to": {"type": "string", "example": "+15005550006"},
+									"from": {"type": "string", "default": "fake-sms"},
+									"body": {"type": "string", "example": "Your code is 123456"}
+								}
+							}
+						}
+					}
+				},
+				"responses": {
+					"201": {
+						"description": "The delivered message",
+						"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Message"}}}
+					},
+					"400": {"$ref": "#/components/responses/Error"}
+				}
+			}
+		}
+	},
+	"components": {
+		"parameters": {
+			"number": {"name": "number", "in": "path", "required": true, "schema": {"type": "string"}, "example": "+4915735983768"},
+			"provider": {"name": "provider", "in": "query", "description": "Provider name, defaults to the one the number was saved from", "schema": {"type": "string"}},
+			"filter": {"name": "filter", "in": "query", "description": "Regular expression the message body must match", "schema": {"type": "string"}},
+			"since": {"name": "since", "in": "query", "description": "Only return messages received after this Go duration ago (2h) or RFC 3339 time", "schema": {"type": "string"}}
+		},
+		"requestBodies": {
+			"MessageKeys": {
+				"description": "The keys of the messages to change, every stored message of the number when omitted",
+				"required": false,
+				"content": {
+					"application/json": {
+						"schema": {"type": "object", "properties": {"keys": {"type": "array", "items": {"type": "string"}}}}
+					}
+				}
+			}
+		},
+		"responses": {
+			"Changed": {
+				"description": "How many messages changed",
+				"content": {"application/json": {"schema": {"type": "object", "properties": {"changed": {"type": "integer"}}}}}
+			},
+			"Error": {
+				"description": "The request failed",
+				"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+			}
+		},
+		"schemas": {
+			"Number": {
+				"type": "object",
+				"properties": {
+					"country": {"type": "string"},
+					"number": {"type": "string", "description": "E.164 form", "example": "+4915735983768"},
+					"display": {"type": "string", "description": "The number as the provider printed it"},
+					"country_code": {"type": "string", "description": "ISO 3166-1 alpha-2 code", "example": "DE"},
+					"calling_code": {"type": "string", "example": "49"},
+					"created_at": {"type": "string", "format": "date-time"},
+					"created_at_text": {"type": "string", "description": "created_at of an older DB that could not be parsed, created_at is then zero"},
+					"provider": {"type": "string"},
+					"status": {"type": "string", "enum": ["active", "idle", "dead"], "description": "Result of the last health check, missing if never checked"},
+					"checked_at": {"type": "string", "format": "date-time", "description": "Missing if never checked"},
+					"last_message_at": {"type": "string", "format": "date-time", "description": "Missing if the last health check saw no message"}
+				}
+			},
+			"Message": {
+				"type": "object",
+				"properties": {
+					"body": {"type": "string"},
+					"created_at": {"type": "string", "format": "date-time"},
+					"created_at_text": {"type": "string", "description": "The timestamp as the provider printed it"},
+					"originator": {"type": "string"},
+					"extracted_code": {"type": "string"},
+					"key": {"type": "string", "description": "Stable dedupe key"},
+					"fetched_at": {"type": "string", "format": "date-time", "description": "Missing until the message was fetched"},
+					"read": {"type": "boolean"}
+				}
+			},
+			"Error": {
+				"type": "object",
+				"properties": {
+					"error": {"type": "string"},
+					"kind": {
+						"type": "string",
+						"enum": ["timeout", "provider_unreachable", "provider_blocked", "layout_changed", "number_not_found", "db_corrupt", "unknown_provider", "invalid_number", "duplicate_number", "bad_request", "method_not_allowed", "not_found", "internal"]
+					}
+				}
+			}
+		}
+	}
+}
+`
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+	"gopkg.in/yaml.v2"
+)
+
+//column a column of a listing
+type column struct {
+	header string
+	//width the most runes a table cell shows, 0 for no limit
+	width int
+}
+
+//listing Records printed by a command, rendered by write in the selected output format
+type listing struct {
+	columns []column
+	rows    [][]string
+	//items the records as serialized by the json, jsonl and yaml formats
+	items []interface{}
+}
+
+//outputFlag adds --output to a command, defaulting to the output setting
+func outputFlag(fs *flag.FlagSet, cfg *config) *string {
+	return fs.String("output", cfg.String("output"), "output format, one of "+strings.Join(outputFormats, ", "))
+}
+
+//checkOutput validates the --output value
+func checkOutput(format string) error {
+	if err := checkOneOf(outputFormats...)(format); err != nil {
+		return newUsageError("invalid output: %s", err)
+	}
+	return nil
+}
+
+//truncate shortens text to width runes, marking the cut with …
+func truncate(text string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width-1]) + "…"
+}
+
+//write renders the listing in format, one of outputFormats
+func (l *listing) write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		return l.writeTable(w)
+	case "tsv":
+		for _, row := range l.rows {
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		return l.writeCSV(w, true)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "\t")
+		return encoder.Encode(l.items)
+	case "jsonl":
+		encoder := json.NewEncoder(w)
+		for _, item := range l.items {
+			if err := encoder.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yaml":
+		return l.writeYAML(w)
+	default:
+		return newUsageError("unknown output format %q", format)
+	}
+}
+
+//writeTable aligns the columns under a header, cutting cells that exceed the column width
+func (l *listing) writeTable(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(l.columns))
+	for idx, col := range l.columns {
+		headers[idx] = col.header
+	}
+	fmt.Fprintln(table, strings.Join(headers, "\t"))
+
+	for _, row := range l.rows {
+		cells := make([]string, len(row))
+		for idx, cell := range row {
+			cells[idx] = truncate(cell, l.columns[idx].width)
+		}
+		fmt.Fprintln(table, strings.Join(cells, "\t"))
+	}
+	return table.Flush()
+}
+
+//writeCSV writes the rows, preceded by a header line when header is set
+func (l *listing) writeCSV(w io.Writer, header bool) error {
+	writer := csv.NewWriter(w)
+	headers := make([]string, len(l.columns))
+	for idx, col := range l.columns {
+		headers[idx] = strings.ToLower(strings.ReplaceAll(col.header, " ", "_"))
+	}
+	if header {
+		if err := writer.Write(headers); err != nil {
+			return err
+		}
+	}
+	if err := writer.WriteAll(l.rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+//writeYAML serializes the items with the same field names as the json format
+func (l *listing) writeYAML(w io.Writer) error {
+	data, err := json.Marshal(l.items)
+	if err != nil {
+		return err
+	}
+
+	//numbers stay numbers and times stay in RFC 3339 when decoded like this
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&generic); err != nil {
+		return err
+	}
+
+	data, err = yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func numbersListing(numbers fakesms.Numbers) *listing {
+	l := &listing{
+		columns: []column{
+			{header: "NUMBER"}, {header: "COUNTRY", width: 24}, {header: "PROVIDER", width: 20},
+			{header: "CREATED AT"}, {header: "STATUS"},
+		},
+		items: make([]interface{}, 0, len(numbers)),
+	}
+	for _, number := range numbers {
+		l.rows = append(l.rows, []string{
+			number.Number, singleLine(number.Country), number.ProviderName(), formatTime(number.CreatedAt),
+			orDash(string(number.Status)),
+		})
+		l.items = append(l.items, number)
+	}
+	return l
+}
+
+//savedNumber a saved number and when its messages were last fetched, the records of numbers list
+type savedNumber struct {
+	fakesms.Number
+	LastCheck time.Time
+}
+
+//MarshalJSON adds last_check to the fields of the number, leaving it out if it was never fetched
+func (n savedNumber) MarshalJSON() ([]byte, error) {
+	data, err := n.Number.MarshalJSON()
+	if err != nil || n.LastCheck.IsZero() {
+		return data, err
+	}
+	lastCheck, err := json.Marshal(n.LastCheck)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data[:len(data)-1], `,"last_check":`...)
+	return append(append(data, lastCheck...), '}'), nil
+}
+
+//savedNumbersListing numbersListing with a LAST CHECK column, lastChecks holds the time of each number
+func savedNumbersListing(numbers fakesms.Numbers, lastChecks []time.Time) *listing {
+	l := numbersListing(numbers)
+	l.columns = append(l.columns, column{header: "LAST CHECK"})
+	for idx := range numbers {
+		l.rows[idx] = append(l.rows[idx], formatTime(lastChecks[idx]))
+		l.items[idx] = savedNumber{Number: numbers[idx], LastCheck: lastChecks[idx]}
+	}
+	return l
+}
+
+func messagesListing(messages fakesms.Messages) *listing {
+	l := &listing{
+		columns: []column{
+			{header: "KEY"}, {header: "SENDER", width: 20}, {header: "RECEIVED AT"}, {header: "CODE", width: 12},
+			{header: "BODY", width: 72}, {header: "READ"},
+		},
+		items: make([]interface{}, 0, len(messages)),
+	}
+	for _, message := range messages {
+		l.rows = append(l.rows, []string{
+			message.Key, singleLine(message.Originator), formatTime(message.ReceivedAt()), orDash(message.ExtractedCode),
+			singleLine(message.Body), fmt.Sprint(message.Read),
+		})
+		l.items = append(l.items, message)
+	}
+	return l
+}
+
+func healthListing(reports []fakesms.HealthReport) *listing {
+	l := &listing{
+		columns: []column{
+			{header: "NUMBER"}, {header: "STATUS"}, {header: "LAST MESSAGE AT"}, {header: "REPLACEMENT"},
+		},
+		items: make([]interface{}, 0, len(reports)),
+	}
+	for _, report := range reports {
+		replacement := "-"
+		if report.Replacement != nil {
+			replacement = report.Replacement.Number
+		}
+		l.rows = append(l.rows, []string{
+			report.Number.Number, string(report.Number.Status), formatTime(report.Number.LastMessageAt), replacement,
+		})
+		l.items = append(l.items, report)
+	}
+	return l
+}
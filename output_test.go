@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+func TestListingFormats(t *testing.T) {
+	numbers := fakesms.Numbers{
+		{Number: "+447700900123", Country: "United Kingdom of Great Britain and Northern Ireland", Provider: "receive-smss"},
+		{Number: "+4915735983768", Country: "Germany", Provider: "receive-smss", Status: fakesms.StatusActive},
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := numbersListing(numbers).write(buffer, "table"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NUMBER") {
+		t.Fatalf("expected a header and 2 rows, got %q", buffer.String())
+	}
+	if !strings.Contains(lines[1], "United Kingdom of Great…") {
+		t.Errorf("expected the long country to be cut, got %q", lines[1])
+	}
+	column := func(line string) int {
+		return utf8.RuneCountInString(line[:strings.Index(line, "receive-smss")])
+	}
+	if column(lines[1]) != column(lines[2]) {
+		t.Errorf("expected aligned columns, got\n%s", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := numbersListing(numbers).write(buffer, "jsonl"); err != nil {
+		t.Fatal(err)
+	}
+	if lines = strings.Split(strings.TrimSpace(buffer.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"status":"active"`) {
+		t.Errorf("expected one JSON object per line, got %q", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := numbersListing(numbers).write(buffer, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buffer.String(), "number,country,provider,created_at,status\n") {
+		t.Errorf("unexpected csv header in %q", buffer.String())
+	}
+}
+
+func TestMessagesListingReceivedAt(t *testing.T) {
+	fetchedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	messages := fakesms.Messages{
+		{Key: "a", Originator: "Acme", Body: "Your code is 4242", CreatedAt: fetchedAt.Add(-time.Hour), FetchedAt: fetchedAt},
+		{Key: "b", Originator: "Bob", Body: "hello", CreatedAtText: "sometime", FetchedAt: fetchedAt},
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := messagesListing(messages).write(buffer, "tsv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	for idx, want := range []string{"2026-10-18T11:00:00Z", "2026-10-18T12:00:00Z"} {
+		if fields := strings.Split(lines[idx], "\t"); len(fields) < 3 || fields[2] != want {
+			t.Errorf("row %d: expected the time the message was received, %s, got %q", idx, want, lines[idx])
+		}
+	}
+}
+
+func TestSavedNumbersListingLastCheck(t *testing.T) {
+	lastCheck := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	numbers := fakesms.Numbers{
+		{Number: "+447700900123", Country: "United Kingdom", Provider: "receive-smss"},
+		{Number: "+4915735983768", Country: "Germany", Provider: "receive-smss"},
+	}
+	l := savedNumbersListing(numbers, []time.Time{{}, lastCheck})
+
+	buffer := &bytes.Buffer{}
+	if err := l.write(buffer, "tsv"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "\t-") || !strings.HasSuffix(lines[1], "\t2026-10-18T12:00:00Z") {
+		t.Errorf("expected the last check in the last column, got %q", buffer.String())
+	}
+
+	buffer.Reset()
+	if err := l.write(buffer, "jsonl"); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || strings.Contains(lines[0], "last_check") || !strings.Contains(lines[1], `"number":"+4915735983768"`) ||
+		!strings.HasSuffix(lines[1], `,"last_check":"2026-10-18T12:00:00Z"}`) {
+		t.Errorf("expected last_check only on the fetched number, got %q", buffer.String())
+	}
+}
@@ -0,0 +1,115 @@
+package fakesms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//AllProviders The provider name that asks every aggregated provider at once, see Client.AggregateProviders
+const AllProviders = "all"
+
+//DefaultAggregateTimeout How long each provider may take to answer when they are asked together
+const DefaultAggregateTimeout = 30 * time.Second
+
+//ProviderFailure A provider that failed while the others were asked
+type ProviderFailure struct {
+	Provider string
+	Err      error
+}
+
+func (f ProviderFailure) Error() string {
+	return fmt.Sprintf("%s: %s", f.Provider, f.Err)
+}
+
+//Unwrap lets errors.Is see the error kind of the provider
+func (f ProviderFailure) Unwrap() error {
+	return f.Err
+}
+
+//WithAggregateProviders Asks the named providers for AllProviders, see Client.AggregateProviders
+func WithAggregateProviders(names ...string) Option {
+	return func(c *Client) {
+		c.Aggregate = names
+	}
+}
+
+//WithAggregateTimeout Gives each provider asked for AllProviders at most timeout to answer
+func WithAggregateTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.AggregateTimeout = timeout
+	}
+}
+
+//AggregateProviders Returns the providers asked for AllProviders, Client.Aggregate or, when that is
+//empty, every registered provider but the mock, whose numbers only exist locally
+func (c *Client) AggregateProviders() []string {
+	names := make([]string, 0)
+	if len(c.Aggregate) > 0 {
+		for _, name := range c.Aggregate {
+			if name != AllProviders {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	for _, name := range Providers() {
+		if name != MockProvider {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+//AvailableNumbersFromAll Asks the aggregated providers concurrently, each within AggregateTimeout,
+//and merges the numbers they offer. A number listed by several providers is kept once, tagged with the
+//first of them in AggregateProviders order. Failing providers are returned in the same order while the
+//numbers of the others are still listed, only when every provider fails the failures are the error
+func (c *Client) AvailableNumbersFromAll(ctx context.Context) (Numbers, []ProviderFailure, error) {
+	timeout := c.AggregateTimeout
+	if timeout <= 0 {
+		timeout = DefaultAggregateTimeout
+	}
+
+	names := c.AggregateProviders()
+	results := make([]Numbers, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for idx, name := range names {
+		wg.Add(1)
+		go func(idx int, name string) {
+			defer wg.Done()
+			providerCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			results[idx], errs[idx] = c.AvailableNumbers(providerCtx, name)
+		}(idx, name)
+	}
+	wg.Wait()
+
+	numbers := make(Numbers, 0)
+	failures := make([]ProviderFailure, 0)
+	seen := make(map[string]bool)
+	for idx, name := range names {
+		if errs[idx] != nil {
+			failures = append(failures, ProviderFailure{Provider: name, Err: errs[idx]})
+			continue
+		}
+		for _, number := range results[idx] {
+			if !seen[number.Number] {
+				seen[number.Number] = true
+				numbers = append(numbers, number)
+			}
+		}
+	}
+
+	if len(names) > 0 && len(failures) == len(names) {
+		//the first failure decides the error kind
+		err := fmt.Errorf("every provider failed, %w", failures[0])
+		for _, failure := range failures[1:] {
+			err = fmt.Errorf("%w; %s", err, failure)
+		}
+		return nil, failures, err
+	}
+	return numbers, failures, nil
+}
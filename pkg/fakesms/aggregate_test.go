@@ -0,0 +1,73 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAvailableNumbersFromAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	static := registerTestProvider(t, "static")
+	static.numbers = []Number{
+		{Number: "+44 7700 900123", Country: "United Kingdom"},
+		{Number: "+33612345678", Country: "France"},
+	}
+	registerTestProvider(t, "slow").slow = true
+
+	client := newTestClient(t,
+		WithHTTPClient(&http.Client{}),
+		WithBaseURL(DefaultProvider, server.URL), WithAggregateTimeout(100*time.Millisecond),
+		WithAggregateProviders("static", MockProvider, DefaultProvider, "slow"),
+	)
+	numbers, failures, err := client.AvailableNumbersFromAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the number both offer is listed once, with the provider named first
+	want := []string{"+447700900123 static", "+33612345678 static", "+15005550006 mock", "+4915735983768 mock"}
+	if len(numbers) != len(want) {
+		t.Fatalf("expected %d numbers, got %v", len(want), numbers)
+	}
+	for idx, number := range numbers {
+		if got := number.Number + " " + number.Provider; got != want[idx] {
+			t.Errorf("number %d: expected %s, got %s", idx, want[idx], got)
+		}
+	}
+	if len(failures) != 2 || failures[0].Provider != DefaultProvider || failures[1].Provider != "slow" {
+		t.Fatalf("expected %s and slow to fail, got %v", DefaultProvider, failures)
+	}
+	for _, failure := range failures {
+		if !errors.Is(failure, ErrProviderUnreachable) {
+			t.Errorf("expected %s to be unreachable, got %v", failure.Provider, failure.Err)
+		}
+	}
+
+	number, err := client.FirstAvailable(context.Background(), AllProviders, "DE")
+	if err != nil || number.Number != "+4915735983768" || number.Provider != MockProvider {
+		t.Errorf("expected the German mock number, got %v, %v", number, err)
+	}
+
+	//only when every provider fails the listing fails
+	client.Aggregate = []string{DefaultProvider, "slow"}
+	if _, failures, err = client.AvailableNumbersFromAll(context.Background()); !errors.Is(err, ErrProviderUnreachable) ||
+		len(failures) != 2 || !strings.Contains(err.Error(), "slow") {
+		t.Errorf("expected every provider to fail, got %v", err)
+	}
+
+	client.Aggregate = nil
+	for _, name := range client.AggregateProviders() {
+		if name == MockProvider {
+			t.Error("expected the mock provider to be left out by default")
+		}
+	}
+}
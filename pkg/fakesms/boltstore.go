@@ -0,0 +1,390 @@
+package fakesms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	boltFileName     = "db.bolt"
+	migratedFileName = "db.json.migrated"
+	//boltLockTimeout how long to wait for another process to release the DB
+	boltLockTimeout = 30 * time.Second
+	//boltSchemaMeta the metadata key recording the layout version of the DB
+	boltSchemaMeta = "schema_version"
+	//boltSchema the current layout version, 2 keys numbers by their E.164 form
+	boltSchema = "2"
+)
+
+var (
+	numbersBucket  = []byte("numbers")
+	messagesBucket = []byte("messages")
+	metaBucket     = []byte("meta")
+)
+
+//BoltStore The embedded key-value store, it keeps everything in <Dir>/db.bolt.
+//The file is opened for each operation, so several processes can share it.
+//An existing db.json in the same directory is imported the first time the store is used
+type BoltStore struct {
+	//Dir the directory holding the DB file, DefaultDBDir() when empty
+	Dir string
+}
+
+//NewBoltStore Creates a store in dir, an empty dir selects DefaultDBDir()
+func NewBoltStore(dir string) *BoltStore {
+	return &BoltStore{Dir: dir}
+}
+
+func (b *BoltStore) dir() string {
+	if b.Dir == "" {
+		return DefaultDBDir()
+	}
+	return b.Dir
+}
+
+//Path Returns the path of the DB file
+func (b *BoltStore) Path() string {
+	return filepath.Join(b.dir(), boltFileName)
+}
+
+//open opens the DB, creating the buckets and importing db.json on first use
+func (b *BoltStore) open() (*bolt.DB, error) {
+	dbDir := b.dir()
+	if err := os.MkdirAll(dbDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create DB directory at %s: %w", dbDir, err)
+	}
+
+	dbPath := b.Path()
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: boltLockTimeout})
+	if errors.Is(err, bolt.ErrInvalid) || errors.Is(err, bolt.ErrChecksum) || errors.Is(err, bolt.ErrVersionMismatch) {
+		return nil, fmt.Errorf("%w: failed to open DB file %s: %s", ErrDBCorrupt, dbPath, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB file %s: %w", dbPath, err)
+	}
+
+	migrated := -1
+	err = db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(numbersBucket) == nil {
+			for _, name := range [][]byte{numbersBucket, messagesBucket, metaBucket} {
+				if _, err := tx.CreateBucket(name); err != nil {
+					return err
+				}
+			}
+			if migrated, err = b.migrate(tx); err != nil {
+				return err
+			}
+		}
+		return boltUpgrade(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize DB file %s: %w", dbPath, err)
+	}
+
+	//only once the import is committed db.json may go, a failed commit leaves it in place
+	if migrated >= 0 {
+		jsonPath := NewJSONStore(b.Dir).Path()
+		migratedPath := filepath.Join(dbDir, migratedFileName)
+		if err = os.Rename(jsonPath, migratedPath); err != nil {
+			db.Close()
+			return nil, err
+		}
+		log.Printf("Migrated %d number(s) from %s to %s\n", migrated, migratedPath, dbPath)
+	}
+
+	return db, nil
+}
+
+//migrate imports the content of db.json into a freshly created DB and returns the number of
+//imported numbers, -1 when there is no db.json. The caller renames the JSON file after the commit
+func (b *BoltStore) migrate(tx *bolt.Tx) (int, error) {
+	jsonStore := NewJSONStore(b.Dir)
+	if _, err := os.Stat(jsonStore.Path()); os.IsNotExist(err) {
+		return -1, nil
+	}
+
+	document, err := jsonStore.view()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, number := range document.Numbers {
+		if err = putJSON(tx.Bucket(numbersBucket), number.Number, number); err != nil {
+			return 0, err
+		}
+	}
+	for number, messages := range document.Messages {
+		if err = putJSON(tx.Bucket(messagesBucket), number, messages); err != nil {
+			return 0, err
+		}
+	}
+	for key, value := range document.Meta {
+		if err = tx.Bucket(metaBucket).Put([]byte(key), []byte(value)); err != nil {
+			return 0, err
+		}
+	}
+	return len(document.Numbers), nil
+}
+
+//boltUpgrade brings an opened DB to the current layout, tests replace it to make the transaction fail
+var boltUpgrade = upgradeBolt
+
+//upgradeBolt re-keys the numbers and messages of DBs written before numbers were normalized
+func upgradeBolt(tx *bolt.Tx) error {
+	meta := tx.Bucket(metaBucket)
+	if string(meta.Get([]byte(boltSchemaMeta))) == boltSchema {
+		return nil
+	}
+
+	numbers := tx.Bucket(numbersBucket)
+	saved := Numbers{}
+	err := numbers.ForEach(func(key, data []byte) error {
+		number := Number{}
+		if err := json.Unmarshal(data, &number); err != nil {
+			return fmt.Errorf("%w: failed to de-serialize number %s: %s", ErrDBCorrupt, key, err)
+		}
+		saved = append(saved, number)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for idx := range saved {
+		number := &saved[idx]
+		key := number.Number
+		//numbers that are not valid are kept as they are
+		number.Normalize()
+		if err = numbers.Delete([]byte(key)); err != nil {
+			return err
+		}
+		if err = putJSON(numbers, number.Number, number); err != nil {
+			return err
+		}
+	}
+
+	bucket := tx.Bucket(messagesBucket)
+	stored := make(map[string]Messages)
+	err = bucket.ForEach(func(key, data []byte) error {
+		messages := Messages{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("%w: failed to de-serialize the messages of %s: %s", ErrDBCorrupt, key, err)
+		}
+		stored[string(key)] = messages
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for key, messages := range stored {
+		if normalized := numberKey(key); normalized != key {
+			if err = bucket.Delete([]byte(key)); err != nil {
+				return err
+			}
+			existing := Messages{}
+			if _, err = getJSON(bucket, normalized, &existing); err != nil {
+				return err
+			}
+			if err = putJSON(bucket, normalized, append(existing, messages...)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return meta.Put([]byte(boltSchemaMeta), []byte(boltSchema))
+}
+
+func putJSON(bucket *bolt.Bucket, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
+
+//getJSON de-serializes the value of key into value, returns false when the key is not set
+func getJSON(bucket *bolt.Bucket, key string, value interface{}) (bool, error) {
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return true, fmt.Errorf("%w: failed to de-serialize the entry %s: %s", ErrDBCorrupt, key, err)
+	}
+	return true, nil
+}
+
+func (b *BoltStore) view(fn func(*bolt.Tx) error) error {
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (b *BoltStore) update(fn func(*bolt.Tx) error) error {
+	db, err := b.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+//AddNumber implements Store
+func (b *BoltStore) AddNumber(number *Number) error {
+	if err := number.Normalize(); err != nil {
+		return err
+	}
+	return b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(numbersBucket)
+		if bucket.Get([]byte(number.Number)) != nil {
+			return fmt.Errorf("%w: %s", ErrDuplicateNumber, number.Number)
+		}
+		return putJSON(bucket, number.Number, number)
+	})
+}
+
+//ListNumbers implements Store, the numbers are sorted
+func (b *BoltStore) ListNumbers() (Numbers, error) {
+	numbers := Numbers{}
+	err := b.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(numbersBucket).ForEach(func(key, data []byte) error {
+			number := Number{}
+			if err := json.Unmarshal(data, &number); err != nil {
+				return fmt.Errorf("%w: failed to de-serialize number %s: %s", ErrDBCorrupt, key, err)
+			}
+			numbers = append(numbers, number)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return numbers, nil
+}
+
+//GetNumber implements Store
+func (b *BoltStore) GetNumber(number string) (*Number, error) {
+	number = numberKey(number)
+	saved := &Number{}
+	err := b.view(func(tx *bolt.Tx) error {
+		found, err := getJSON(tx.Bucket(numbersBucket), number, saved)
+		if err == nil && !found {
+			err = fmt.Errorf("%w: %s is not saved", ErrNumberNotFound, number)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+//UpdateNumber implements Store
+func (b *BoltStore) UpdateNumber(number string, modify func(*Number) error) error {
+	number = numberKey(number)
+	return b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(numbersBucket)
+		saved := &Number{}
+		found, err := getJSON(bucket, number, saved)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%w: %s is not saved", ErrNumberNotFound, number)
+		}
+
+		if err = modify(saved); err != nil {
+			return err
+		}
+		if saved.Number != number {
+			if err = bucket.Delete([]byte(number)); err != nil {
+				return err
+			}
+		}
+		return putJSON(bucket, saved.Number, saved)
+	})
+}
+
+//RemoveNumber implements Store
+func (b *BoltStore) RemoveNumber(number string) error {
+	number = numberKey(number)
+	return b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(numbersBucket)
+		if bucket.Get([]byte(number)) == nil {
+			return fmt.Errorf("%w: %s is not saved", ErrNumberNotFound, number)
+		}
+		if err := bucket.Delete([]byte(number)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(messagesBucket).Delete([]byte(number)); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Delete([]byte(lastCheckMeta + number))
+	})
+}
+
+//Messages implements Store
+func (b *BoltStore) Messages(number string) (Messages, error) {
+	number = numberKey(number)
+	messages := Messages{}
+	err := b.view(func(tx *bolt.Tx) error {
+		_, err := getJSON(tx.Bucket(messagesBucket), number, &messages)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+//UpdateMessages implements Store
+func (b *BoltStore) UpdateMessages(number string, modify func(Messages) (Messages, error)) error {
+	number = numberKey(number)
+	return b.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(messagesBucket)
+		messages := Messages{}
+		if _, err := getJSON(bucket, number, &messages); err != nil {
+			return err
+		}
+
+		messages, err := modify(messages)
+		if err != nil {
+			return err
+		}
+
+		if len(messages) == 0 {
+			return bucket.Delete([]byte(number))
+		}
+		return putJSON(bucket, number, messages)
+	})
+}
+
+//Meta implements Store
+func (b *BoltStore) Meta(key string) (string, error) {
+	value := ""
+	err := b.view(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket(metaBucket).Get([]byte(key)))
+		return nil
+	})
+	return value, err
+}
+
+//SetMeta implements Store
+func (b *BoltStore) SetMeta(key, value string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		if value == "" {
+			return tx.Bucket(metaBucket).Delete([]byte(key))
+		}
+		return tx.Bucket(metaBucket).Put([]byte(key), []byte(value))
+	})
+}
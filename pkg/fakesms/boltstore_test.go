@@ -0,0 +1,84 @@
+package fakesms
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltStoreMigratesJSON(t *testing.T) {
+	dir := tempDir(t)
+
+	//the numbers array written by older versions
+	legacy := `[{"country":"Germany","number":"+4915735983768","created_at":"2020-10-10 10:10:10 Saturday"}]`
+	if err := ioutil.WriteFile(filepath.Join(dir, "db.json"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewBoltStore(dir)
+	numbers, err := store.ListNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != 1 || numbers[0].Number != "+4915735983768" || numbers[0].Country != "Germany" {
+		t.Fatalf("unexpected numbers after migration: %+v", numbers)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, "db.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected db.json to be renamed after migration, stat returned %v", err)
+	}
+
+	if err = store.RemoveNumber("+4915735983768"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.GetNumber("+4915735983768"); err == nil {
+		t.Fatal("expected the removed number to be gone")
+	}
+}
+
+func TestBoltStoreKeepsJSONWhenImportFails(t *testing.T) {
+	dir := tempDir(t)
+
+	legacy := `[{"country":"Germany","number":"+4915735983768","created_at":"2020-10-10 10:10:10 Saturday"}]`
+	jsonPath := filepath.Join(dir, "db.json")
+	if err := ioutil.WriteFile(jsonPath, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	//the import succeeds, but the transaction holding it is rolled back
+	boltUpgrade = func(tx *bolt.Tx) error {
+		if err := upgradeBolt(tx); err != nil {
+			return err
+		}
+		return errors.New("disk full")
+	}
+	t.Cleanup(func() {
+		boltUpgrade = upgradeBolt
+	})
+
+	store := NewBoltStore(dir)
+	if _, err := store.ListNumbers(); err == nil {
+		t.Fatal("expected the import to fail")
+	}
+	data, err := ioutil.ReadFile(jsonPath)
+	if err != nil || string(data) != legacy {
+		t.Fatalf("expected db.json to be left as it was, got %q, %v", data, err)
+	}
+
+	//nothing of the failed import was kept, the next open imports db.json again
+	boltUpgrade = upgradeBolt
+	numbers, err := store.ListNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != 1 || numbers[0].Number != "+4915735983768" {
+		t.Fatalf("unexpected numbers after the second import: %+v", numbers)
+	}
+	if _, err = os.Stat(jsonPath); !os.IsNotExist(err) {
+		t.Fatalf("expected db.json to be renamed after the import, stat returned %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//Client Ties the providers and the local DB together
+type Client struct {
+	//HTTPClient used by every provider, see NewHTTPClient
+	HTTPClient *http.Client
+	//HTTPClients overrides HTTPClient per provider name, e.g. to route one provider through a proxy
+	HTTPClients map[string]*http.Client
+	//BaseURLs overrides the site address per provider name
+	BaseURLs map[string]string
+	//DefaultProvider the provider used when none is named, DefaultProvider when empty
+	DefaultProvider string
+	//Store where numbers are saved
+	Store Store
+	//StateDir where the providers keep their state between runs, like cookies (see SessionPath) and the
+	//inbox of the mock provider. Cookies are only kept in memory and the inbox is in DefaultDBDir() when empty
+	StateDir string
+	//DebugDir where the raw pages the providers fetch are saved for bug reports, nowhere when empty
+	DebugDir string
+	//Aggregate the providers asked for AllProviders, see AggregateProviders
+	Aggregate []string
+	//AggregateTimeout how long each of them may take, DefaultAggregateTimeout when 0
+	AggregateTimeout time.Duration
+
+	sessions      map[string]*Session
+	sessionsMutex sync.Mutex
+}
+
+//Option Configures a Client
+type Option func(*Client)
+
+//WithHTTPClient Makes the providers send their requests through client. Use a Transport
+//to keep the retries and the User-Agent of the default client
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = client
+	}
+}
+
+//WithProviderHTTPClient Makes the named provider send its requests through client instead of the shared one
+func WithProviderHTTPClient(provider string, client *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClients[provider] = client
+	}
+}
+
+//WithBaseURL Points the named provider at another address, e.g. a test server
+func WithBaseURL(provider, baseURL string) Option {
+	return func(c *Client) {
+		c.BaseURLs[provider] = baseURL
+	}
+}
+
+//WithDefaultProvider Uses the named provider when a call names none
+func WithDefaultProvider(name string) Option {
+	return func(c *Client) {
+		c.DefaultProvider = name
+	}
+}
+
+//WithStore Saves the numbers in store instead of the JSON file in DefaultDBDir()
+func WithStore(store Store) Option {
+	return func(c *Client) {
+		c.Store = store
+	}
+}
+
+//WithStateDir Keeps the state of the providers in dir between runs, see Client.StateDir
+func WithStateDir(dir string) Option {
+	return func(c *Client) {
+		c.StateDir = dir
+	}
+}
+
+//WithDebugDump Saves every page the providers fetch in dir, see Client.DebugDir
+func WithDebugDump(dir string) Option {
+	return func(c *Client) {
+		c.DebugDir = dir
+	}
+}
+
+//NewClient Creates a client, by default using NewHTTPClient() and the JSON store in DefaultDBDir()
+func NewClient(options ...Option) *Client {
+	client := &Client{
+		HTTPClient:  NewHTTPClient(),
+		HTTPClients: make(map[string]*http.Client),
+		BaseURLs:    make(map[string]string),
+		Store:       NewJSONStore(""),
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+//defaultProvider the provider used when a call names none
+func (c *Client) defaultProvider() string {
+	if c.DefaultProvider == "" {
+		return DefaultProvider
+	}
+	return c.DefaultProvider
+}
+
+//Provider Builds the named provider with the client settings, an empty name selects the default provider
+func (c *Client) Provider(name string) (Provider, error) {
+	if name == "" {
+		name = c.defaultProvider()
+	}
+	if name == AllProviders {
+		return nil, fmt.Errorf("%w: %s only lists numbers, name a single provider", ErrUnknownProvider, name)
+	}
+	httpClient, exists := c.HTTPClients[name]
+	if !exists {
+		httpClient = c.HTTPClient
+	}
+	if c.DebugDir != "" {
+		httpClient = debugClient(httpClient, c.DebugDir, name)
+	}
+	return NewProvider(name, ProviderOptions{
+		HTTPClient: httpClient,
+		BaseURL:    c.BaseURLs[name],
+		Session:    c.session(name),
+		StateDir:   c.StateDir,
+	})
+}
+
+//AvailableNumbers Lists the numbers offered by the named provider, normalized to E.164. AllProviders
+//merges the numbers of every aggregated provider that answers, see AvailableNumbersFromAll
+func (c *Client) AvailableNumbers(ctx context.Context, providerName string) (Numbers, error) {
+	if providerName == AllProviders {
+		numbers, _, err := c.AvailableNumbersFromAll(ctx)
+		return numbers, err
+	}
+	provider, err := c.Provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers, err := provider.AvailableNumbers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range numbers {
+		numbers[idx].Provider = provider.Name()
+		//numbers that are not valid are listed as the provider printed them
+		numbers[idx].Normalize()
+	}
+	return Numbers(numbers), nil
+}
+
+//AvailableNumber Returns the number if the named provider currently offers it, ErrNumberNotFound otherwise
+func (c *Client) AvailableNumber(ctx context.Context, providerName, number string) (*Number, error) {
+	if providerName == "" {
+		providerName = c.defaultProvider()
+	}
+	numbers, err := c.AvailableNumbers(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := numbers.Find(number)
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: %s is not offered by %s", ErrNumberNotFound, number, providerName)
+	}
+	return &numbers[idx], nil
+}
+
+//FirstAvailable Returns the first number of a country offered by the named provider that is
+//not saved yet, see Number.MatchesCountry. ErrNumberNotFound when there is none
+func (c *Client) FirstAvailable(ctx context.Context, providerName, country string) (*Number, error) {
+	if providerName == "" {
+		providerName = c.defaultProvider()
+	}
+	numbers, err := c.AvailableNumbers(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := c.Store.ListNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, number := range numbers.ByCountry(country) {
+		if saved.Find(number.Number) == -1 {
+			return &number, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s offers no unused number in %s", ErrNumberNotFound, providerName, country)
+}
+
+//ResolveNumber Returns the saved entry of a number. Numbers that are not saved
+//are normalized and tagged with providerName or the default provider, ErrInvalidNumber if that fails
+func (c *Client) ResolveNumber(number, providerName string) (*Number, error) {
+	saved, err := c.Store.GetNumber(number)
+	if err == nil {
+		if providerName != "" && providerName != saved.ProviderName() {
+			saved.Provider = providerName
+		}
+		return saved, nil
+	}
+	if !errors.Is(err, ErrNumberNotFound) {
+		return nil, err
+	}
+	if providerName == "" {
+		providerName = c.defaultProvider()
+	}
+	resolved := &Number{Number: number, Provider: providerName}
+	if err = resolved.Normalize(); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+//Messages Fetches the messages of a number from the provider it belongs to, extracts
+//their codes and stores them, see Fetch
+func (c *Client) Messages(ctx context.Context, number *Number) (Messages, error) {
+	messages, _, err := c.Fetch(ctx, number)
+	return messages, err
+}
+
+//Fetch Gets the messages of a number from its provider and merges them into the store.
+//It returns every fetched message and, separately, the ones that were not stored before
+func (c *Client) Fetch(ctx context.Context, number *Number) (messages Messages, fresh Messages, err error) {
+	provider, err := c.Provider(number.ProviderName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fetched, err := provider.Messages(ctx, number.Number)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	messages = extractCodes(Messages(fetched))
+	fresh, err = c.storeMessages(number.Number, messages, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages, fresh, nil
+}
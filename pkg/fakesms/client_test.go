@@ -0,0 +1,73 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const numbersPage = `<html><body><div class="number-boxes">
+<div class="number-boxes-item"><div class="row"><h4>+4915735983768</h4><h5>Germany</h5></div></div>
+</div></body></html>`
+
+const messagesPage = `<html><body><table><tbody>
+<tr><td>Acme</td><td>Your code is 4242</td><td>2 mins ago</td></tr>
+</tbody></table></body></html>`
+
+//newTestSite serves a copy of receive-smss.com with one number
+func newTestSite() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, numbersPage)
+		case "/sms/4915735983768/":
+			fmt.Fprint(w, messagesPage)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestClientAgainstTestSite(t *testing.T) {
+	server := newTestSite()
+	defer server.Close()
+	client := newTestClient(t, WithHTTPClient(server.Client()), WithBaseURL(DefaultProvider, server.URL))
+	ctx := context.Background()
+
+	numbers, err := client.AvailableNumbers(ctx, "")
+	if err != nil || len(numbers) != 1 || numbers[0].Number != "+4915735983768" || numbers[0].Country != "Germany" {
+		t.Fatalf("expected the number of the test site, got %v %v", numbers, err)
+	}
+	if err = client.Store.AddNumber(&numbers[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	number, err := client.ResolveNumber("+4915735983768", "")
+	if err != nil || number.ProviderName() != DefaultProvider {
+		t.Fatalf("expected the saved number, got %v %v", number, err)
+	}
+	messages, err := client.Messages(ctx, number)
+	if err != nil || len(messages) != 1 || messages[0].Originator != "Acme" || messages[0].ExtractedCode != "4242" {
+		t.Errorf("expected the message with its code, got %v %v", messages, err)
+	}
+
+	unknown := &Number{Number: "+447700900123"}
+	if _, err = client.Messages(ctx, unknown); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("expected ErrNumberNotFound for a number without a page, got %v", err)
+	}
+
+	if err = client.Store.RemoveNumber("+4915735983768"); err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Store.RemoveNumber("+4915735983768"); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("expected ErrNumberNotFound for a removed number, got %v", err)
+	}
+
+	server.Close()
+	if _, err = client.AvailableNumbers(ctx, ""); !errors.Is(err, ErrProviderUnreachable) {
+		t.Errorf("expected ErrProviderUnreachable once the site is down, got %v", err)
+	}
+}
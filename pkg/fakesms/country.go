@@ -0,0 +1,192 @@
+package fakesms
+
+import "strings"
+
+//countryCodes maps the lower case country names printed by the providers to ISO 3166-1 alpha-2 codes
+var countryCodes = map[string]string{
+	"afghanistan":            "AF",
+	"albania":                "AL",
+	"algeria":                "DZ",
+	"andorra":                "AD",
+	"angola":                 "AO",
+	"argentina":              "AR",
+	"armenia":                "AM",
+	"australia":              "AU",
+	"austria":                "AT",
+	"azerbaijan":             "AZ",
+	"bahamas":                "BS",
+	"bahrain":                "BH",
+	"bangladesh":             "BD",
+	"belarus":                "BY",
+	"belgium":                "BE",
+	"bolivia":                "BO",
+	"bosnia and herzegovina": "BA",
+	"brazil":                 "BR",
+	"bulgaria":               "BG",
+	"cambodia":               "KH",
+	"cameroon":               "CM",
+	"canada":                 "CA",
+	"chile":                  "CL",
+	"china":                  "CN",
+	"colombia":               "CO",
+	"costa rica":             "CR",
+	"croatia":                "HR",
+	"cyprus":                 "CY",
+	"czech republic":         "CZ",
+	"denmark":                "DK",
+	"dominican republic":     "DO",
+	"ecuador":                "EC",
+	"egypt":                  "EG",
+	"estonia":                "EE",
+	"ethiopia":               "ET",
+	"finland":                "FI",
+	"france":                 "FR",
+	"georgia":                "GE",
+	"germany":                "DE",
+	"ghana":                  "GH",
+	"greece":                 "GR",
+	"guatemala":              "GT",
+	"hong kong":              "HK",
+	"hungary":                "HU",
+	"iceland":                "IS",
+	"india":                  "IN",
+	"indonesia":              "ID",
+	"iran":                   "IR",
+	"iraq":                   "IQ",
+	"ireland":                "IE",
+	"israel":                 "IL",
+	"italy":                  "IT",
+	"jamaica":                "JM",
+	"japan":                  "JP",
+	"jordan":                 "JO",
+	"kazakhstan":             "KZ",
+	"kenya":                  "KE",
+	"kuwait":                 "KW",
+	"kyrgyzstan":             "KG",
+	"latvia":                 "LV",
+	"lebanon":                "LB",
+	"lithuania":              "LT",
+	"luxembourg":             "LU",
+	"macau":                  "MO",
+	"malaysia":               "MY",
+	"malta":                  "MT",
+	"mexico":                 "MX",
+	"moldova":                "MD",
+	"mongolia":               "MN",
+	"montenegro":             "ME",
+	"morocco":                "MA",
+	"myanmar":                "MM",
+	"nepal":                  "NP",
+	"netherlands":            "NL",
+	"new zealand":            "NZ",
+	"nigeria":                "NG",
+	"north macedonia":        "MK",
+	"norway":                 "NO",
+	"oman":                   "OM",
+	"pakistan":               "PK",
+	"panama":                 "PA",
+	"paraguay":               "PY",
+	"peru":                   "PE",
+	"philippines":            "PH",
+	"poland":                 "PL",
+	"portugal":               "PT",
+	"puerto rico":            "PR",
+	"qatar":                  "QA",
+	"romania":                "RO",
+	"russia":                 "RU",
+	"saudi arabia":           "SA",
+	"serbia":                 "RS",
+	"singapore":              "SG",
+	"slovakia":               "SK",
+	"slovenia":               "SI",
+	"south africa":           "ZA",
+	"south korea":            "KR",
+	"spain":                  "ES",
+	"sri lanka":              "LK",
+	"sweden":                 "SE",
+	"switzerland":            "CH",
+	"taiwan":                 "TW",
+	"tajikistan":             "TJ",
+	"tanzania":               "TZ",
+	"thailand":               "TH",
+	"tunisia":                "TN",
+	"turkey":                 "TR",
+	"uganda":                 "UG",
+	"ukraine":                "UA",
+	"united arab emirates":   "AE",
+	"united kingdom":         "GB",
+	"united states":          "US",
+	"uruguay":                "UY",
+	"uzbekistan":             "UZ",
+	"venezuela":              "VE",
+	"vietnam":                "VN",
+	"yemen":                  "YE",
+	"zimbabwe":               "ZW",
+	//common alternative spellings
+	"usa":                      "US",
+	"us":                       "US",
+	"america":                  "US",
+	"united states of america": "US",
+	"uk":                       "GB",
+	"great britain":            "GB",
+	"england":                  "GB",
+	"britain":                  "GB",
+	"holland":                  "NL",
+	"the netherlands":          "NL",
+	"czechia":                  "CZ",
+	"republic of korea":        "KR",
+	"korea":                    "KR",
+	"russian federation":       "RU",
+	"viet nam":                 "VN",
+	"uae":                      "AE",
+	"macedonia":                "MK",
+}
+
+//CountryCode Returns the ISO 3166-1 alpha-2 code of a country name, "" when it is not known
+func CountryCode(country string) string {
+	return countryCodes[strings.ToLower(strings.Join(strings.Fields(country), " "))]
+}
+
+//MatchesCountry Reports whether the number belongs to country, given either as an ISO code
+//like "DE" or as a name like "Germany". Matching ignores case
+func (n *Number) MatchesCountry(country string) bool {
+	country = strings.TrimSpace(country)
+	if country == "" {
+		return true
+	}
+	if strings.EqualFold(strings.Join(strings.Fields(n.Country), " "), strings.Join(strings.Fields(country), " ")) {
+		return true
+	}
+	code := n.CountryCode
+	if code == "" {
+		code = CountryCode(n.Country)
+	}
+	if code == "" {
+		return false
+	}
+	return strings.EqualFold(code, country) || code == CountryCode(country)
+}
+
+//ByCountry Keeps the numbers of a country, see Number.MatchesCountry. An empty country keeps all
+func (n Numbers) ByCountry(country string) Numbers {
+	matching := Numbers{}
+	for idx := range n {
+		if n[idx].MatchesCountry(country) {
+			matching = append(matching, n[idx])
+		}
+	}
+	return matching
+}
+
+//Countries Returns the distinct countries of the numbers in the order they first appear
+func (n Numbers) Countries() []string {
+	seen := make(map[string]bool)
+	countries := make([]string, 0)
+	for _, number := range n {
+		if !seen[number.Country] {
+			seen[number.Country] = true
+			countries = append(countries, number.Country)
+		}
+	}
+	return countries
+}
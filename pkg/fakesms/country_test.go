@@ -0,0 +1,38 @@
+package fakesms
+
+import "testing"
+
+func TestByCountry(t *testing.T) {
+	numbers := Numbers{
+		{Number: "+4915735983768", Country: "Germany"},
+		{Number: "+447700900123", Country: "United  Kingdom"},
+		{Number: "+15005550006", Country: "United States"},
+		{Number: "+99912345", Country: "Atlantis"},
+	}
+
+	cases := map[string][]string{
+		"DE":             {"+4915735983768"},
+		"de":             {"+4915735983768"},
+		"germany":        {"+4915735983768"},
+		"GB":             {"+447700900123"},
+		"UK":             {"+447700900123"},
+		"United Kingdom": {"+447700900123"},
+		"USA":            {"+15005550006"},
+		"Atlantis":       {"+99912345"},
+		"FR":             {},
+		"":               {"+4915735983768", "+447700900123", "+15005550006", "+99912345"},
+	}
+
+	for country, want := range cases {
+		got := numbers.ByCountry(country)
+		if len(got) != len(want) {
+			t.Errorf("ByCountry(%q) returned %d numbers, want %d", country, len(got), len(want))
+			continue
+		}
+		for idx := range want {
+			if got[idx].Number != want[idx] {
+				t.Errorf("ByCountry(%q)[%d] = %s, want %s", country, idx, got[idx].Number, want[idx])
+			}
+		}
+	}
+}
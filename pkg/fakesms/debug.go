@@ -0,0 +1,73 @@
+package fakesms
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//dumpSequence numbers the dumps, so pages fetched within the same millisecond keep their order
+var dumpSequence int64
+
+//dumpNameUnsafe the characters of a URL path that are replaced in dump file names
+var dumpNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+//debugTransport saves every page a provider fetches, with the request and status in a leading comment
+type debugTransport struct {
+	base     http.RoundTripper
+	dir      string
+	provider string
+}
+
+//debugClient returns a copy of client that saves the pages it fetches in dir
+func debugClient(client *http.Client, dir, provider string) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	dumpClient := *client
+	dumpClient.Transport = &debugTransport{base: base, dir: dir, provider: provider}
+	return &dumpClient
+}
+
+//RoundTrip implements http.RoundTripper
+func (t *debugTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	//a failed dump must not fail the request
+	t.save(request, response, body)
+	return response, nil
+}
+
+func (t *debugTransport) save(request *http.Request, response *http.Response, body []byte) {
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return
+	}
+	page := strings.Trim(dumpNameUnsafe.ReplaceAllString(request.URL.Path, "_"), "_")
+	if page == "" {
+		page = "index"
+	}
+	name := fmt.Sprintf("%s-%s-%04d-%s.html", t.provider, time.Now().Format("20060102-150405.000"),
+		atomic.AddInt64(&dumpSequence, 1), page)
+
+	var dump bytes.Buffer
+	fmt.Fprintf(&dump, "<!-- %s %s -> %s -->\n", request.Method, request.URL, response.Status)
+	dump.Write(body)
+	ioutil.WriteFile(filepath.Join(t.dir, name), dump.Bytes(), 0600)
+}
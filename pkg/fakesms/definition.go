@@ -0,0 +1,214 @@
+package fakesms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"gopkg.in/yaml.v2"
+)
+
+//Definition Describes a receive-SMS site that lists its numbers in boxes and the messages of a
+//number in table rows, so it can be scraped without code. See RegisterDefinition
+type Definition struct {
+	//Name the provider name
+	Name string `yaml:"name" json:"name"`
+	//BaseURL the address of the site, ProviderOptions.BaseURL overrides it
+	BaseURL  string       `yaml:"base_url" json:"base_url"`
+	Numbers  NumbersPage  `yaml:"numbers" json:"numbers"`
+	Messages MessagesPage `yaml:"messages" json:"messages"`
+}
+
+//NumbersPage The page listing the numbers of a site. Selectors are CSS selectors
+type NumbersPage struct {
+	//Path of the page relative to the base URL, the base URL itself when empty
+	Path string `yaml:"path" json:"path"`
+	//Container selects the element holding the boxes, a page without it has a changed layout. Optional
+	Container string `yaml:"container" json:"container"`
+	//Item selects a number box
+	Item string `yaml:"item" json:"item"`
+	//Number and Country select the fields inside a box, boxes missing either are skipped
+	Number  string `yaml:"number" json:"number"`
+	Country string `yaml:"country" json:"country"`
+}
+
+//MessagesPage The page listing the messages of a number. Selectors are CSS selectors
+type MessagesPage struct {
+	//Path of the page relative to the base URL, {number} is replaced by the E.164 number
+	//and {digits} by the number without the +, also in a query string like messages.php?n={digits}.
+	//Numbers redirected to another path are not found
+	Path string `yaml:"path" json:"path"`
+	//Container selects the element holding the rows, a page without it has a changed layout. Optional
+	Container string `yaml:"container" json:"container"`
+	//Row selects a message
+	Row string `yaml:"row" json:"row"`
+	//Cell selects the cells of a row, td when empty. Rows with too few cells are skipped
+	Cell    string         `yaml:"cell" json:"cell"`
+	Columns MessageColumns `yaml:"columns" json:"columns"`
+}
+
+//MessageColumns The positions of the message fields among the cells of a row, counted from 0
+type MessageColumns struct {
+	Sender int `yaml:"sender" json:"sender"`
+	Body   int `yaml:"body" json:"body"`
+	//Time the cell with the time the site printed, see ParseTime
+	Time int `yaml:"time" json:"time"`
+}
+
+//definitionName the names definitions may use
+var definitionName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+//compiledDefinition a validated definition with its selectors compiled
+type compiledDefinition struct {
+	*Definition
+	numbersContainer, numberItem, numberField, countryField cascadia.Selector
+	messagesContainer, messageRow, messageCell              cascadia.Selector
+}
+
+//compileSelector compiles an optional selector, nil when it is empty
+func compileSelector(field, selector string, required bool) (cascadia.Selector, error) {
+	if strings.TrimSpace(selector) == "" {
+		if required {
+			return nil, fmt.Errorf("%s is missing", field)
+		}
+		return nil, nil
+	}
+	compiled, err := cascadia.Compile(selector)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid selector %q: %s", field, selector, err)
+	}
+	return compiled, nil
+}
+
+//compile validates the definition
+func (d *Definition) compile() (*compiledDefinition, error) {
+	if !definitionName.MatchString(d.Name) {
+		return nil, fmt.Errorf("invalid provider name %q, expected lower case letters, digits, ., _ and -", d.Name)
+	}
+	base, err := url.Parse(d.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("%s: expected a base_url like https://example.com/, got %q", d.Name, d.BaseURL)
+	}
+	if !strings.Contains(d.Messages.Path, "{number}") && !strings.Contains(d.Messages.Path, "{digits}") {
+		return nil, fmt.Errorf("%s: messages.path must contain {number} or {digits}", d.Name)
+	}
+	columns := d.Messages.Columns
+	if columns.Sender < 0 || columns.Body < 0 || columns.Time < 0 ||
+		columns.Sender == columns.Body || columns.Sender == columns.Time || columns.Body == columns.Time {
+		return nil, fmt.Errorf("%s: messages.columns must be distinct positions counted from 0", d.Name)
+	}
+
+	compiled := &compiledDefinition{Definition: d}
+	cell := d.Messages.Cell
+	if cell == "" {
+		cell = "td"
+	}
+	for _, selector := range []struct {
+		field    string
+		value    string
+		required bool
+		target   *cascadia.Selector
+	}{
+		{"numbers.container", d.Numbers.Container, false, &compiled.numbersContainer},
+		{"numbers.item", d.Numbers.Item, true, &compiled.numberItem},
+		{"numbers.number", d.Numbers.Number, true, &compiled.numberField},
+		{"numbers.country", d.Numbers.Country, true, &compiled.countryField},
+		{"messages.container", d.Messages.Container, false, &compiled.messagesContainer},
+		{"messages.row", d.Messages.Row, true, &compiled.messageRow},
+		{"messages.cell", cell, true, &compiled.messageCell},
+	} {
+		if *selector.target, err = compileSelector(selector.field, selector.value, selector.required); err != nil {
+			return nil, fmt.Errorf("%s: %s", d.Name, err)
+		}
+	}
+	return compiled, nil
+}
+
+//definitions the compiled definitions registered by RegisterDefinition, by provider name
+var definitions = map[string]*compiledDefinition{}
+
+//RegisterDefinition Registers a provider scraping the site the definition describes. A definition
+//may replace an earlier definition of the same name, e.g. a built-in one, but not a provider written
+//in Go. Like Register it is meant to be called before providers are built
+func RegisterDefinition(definition Definition) error {
+	compiled, err := definition.compile()
+	if err != nil {
+		return err
+	}
+
+	if definition.Name == AllProviders {
+		return fmt.Errorf("provider name %s is reserved", definition.Name)
+	}
+	if _, exists := factories[definition.Name]; exists && definitions[definition.Name] == nil {
+		return fmt.Errorf("provider %s is built in and cannot be redefined", definition.Name)
+	}
+	definitions[definition.Name] = compiled
+	factories[definition.Name] = func(options ProviderOptions) Provider {
+		return newScraper(compiled, options)
+	}
+	return nil
+}
+
+//ReadDefinition Reads a definition from a .yaml, .yml or .json file
+func ReadDefinition(path string) (*Definition, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider definition: %w", err)
+	}
+	definition := &Definition{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(definition)
+	} else {
+		err = yaml.UnmarshalStrict(data, definition)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse provider definition %s: %w", path, err)
+	}
+	return definition, nil
+}
+
+//LoadDefinitions Registers the definitions in the .yaml, .yml and .json files of dir, in file name order,
+//and returns the names of their providers. A missing dir holds no definitions
+func LoadDefinitions(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider definitions: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		definition, err := ReadDefinition(path)
+		if err != nil {
+			return nil, err
+		}
+		if err = RegisterDefinition(*definition); err != nil {
+			return nil, fmt.Errorf("invalid provider definition %s: %w", path, err)
+		}
+		names = append(names, definition.Name)
+	}
+	return names, nil
+}
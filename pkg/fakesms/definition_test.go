@@ -0,0 +1,147 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+//listSiteDefinition a site listing numbers and messages without tables
+const listSiteDefinition = `
+name: list-site
+base_url: https://list-site.example/
+numbers:
+  path: free-numbers
+  item: ul.numbers > li
+  number: .phone
+  country: .flag[title]
+messages:
+  path: inbox/{number}
+  container: "#inbox"
+  row: article
+  cell: span
+  columns: {time: 0, sender: 1, body: 2}
+`
+
+func TestDefinedProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/free-numbers":
+			fmt.Fprint(w, `<ul class="numbers">
+				<li><b class="phone">+44 7700 900123</b> <i class="flag" title="uk">United Kingdom</i></li>
+				<li><b class="phone">+33612345678</b></li>
+			</ul>`)
+		case "/inbox/+447700900123":
+			fmt.Fprint(w, `<div id="inbox">
+				<article><span>2 mins ago</span><span>Acme</span><span>Code: 4242 &#x1F511;</span></article>
+				<article><span>sponsored</span></article>
+			</div>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := tempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "list-site.yaml"), []byte(listSiteDefinition), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "README.txt"), []byte("not a definition"), 0600)
+
+	names, err := LoadDefinitions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		Unregister("list-site")
+	})
+	if len(names) != 1 || names[0] != "list-site" {
+		t.Fatalf("expected the list-site definition, got %v", names)
+	}
+
+	client := newTestClient(t, WithHTTPClient(&http.Client{}), WithBaseURL("list-site", server.URL))
+	numbers, err := client.AvailableNumbers(context.Background(), "list-site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != 1 || numbers[0].Number != "+447700900123" || numbers[0].Provider != "list-site" {
+		t.Fatalf("expected the box with a country, got %v", numbers)
+	}
+
+	messages, err := client.Messages(context.Background(), &numbers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Originator != "Acme" || messages[0].Body != "Code: 4242 🔑" ||
+		messages[0].CreatedAt.IsZero() || messages[0].ExtractedCode != "4242" {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+	if _, err = client.Messages(context.Background(), &Number{Number: "+33612345678", Provider: "list-site"}); err == nil {
+		t.Error("expected a number without inbox to fail")
+	}
+
+	//definitions replace definitions, also for NewReceiveSMSS, but not providers written in Go
+	builtIn := *definitions[receiveSMSSName].Definition
+	mirror := builtIn
+	mirror.BaseURL = "https://mirror.example/"
+	if err = RegisterDefinition(mirror); err != nil {
+		t.Errorf("expected the built-in definition to be replaceable, got %v", err)
+	}
+	if baseURL := NewReceiveSMSS(nil, "").baseURL; baseURL != mirror.BaseURL {
+		t.Errorf("expected the replacement to be used, got %s", baseURL)
+	}
+	if err = RegisterDefinition(builtIn); err != nil {
+		t.Fatal(err)
+	}
+	mock := strings.Replace(listSiteDefinition, "name: list-site", "name: mock", 1)
+	broken := strings.Replace(listSiteDefinition, "row: article", "row: article[", 1)
+	for name, source := range map[string]string{"mock": mock, "broken": broken} {
+		path := filepath.Join(dir, "list-site.yaml")
+		ioutil.WriteFile(path, []byte(source), 0600)
+		if _, err = LoadDefinitions(dir); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: expected an error naming the file, got %v", name, err)
+		}
+	}
+}
+
+func TestScraperQueryPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `<ul class="numbers"></ul>`)
+			return
+		case r.URL.Path != "/messages.php" || r.URL.Query().Get("n") != "+447700900123":
+			//retired numbers are sent back to the number list
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<div id="inbox"><article><span>2 mins ago</span><span>Acme</span><span>Code: 4242</span></article></div>`)
+	}))
+	defer server.Close()
+
+	definition := Definition{}
+	if err := yaml.UnmarshalStrict([]byte(strings.Replace(listSiteDefinition, "inbox/{number}", "messages.php?n={number}", 1)), &definition); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := definition.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper := newScraper(compiled, ProviderOptions{HTTPClient: &http.Client{}, BaseURL: server.URL})
+
+	messages, err := scraper.Messages(context.Background(), "+447700900123")
+	if err != nil || len(messages) != 1 || messages[0].Originator != "Acme" {
+		t.Errorf("expected the message of the number, got %v %v", messages, err)
+	}
+	if _, err = scraper.Messages(context.Background(), "+33612345678"); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("expected a redirected number to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package fakesms
+
+import "errors"
+
+//Error kinds returned by providers and the DB, test for them with errors.Is
+var (
+	//ErrProviderUnreachable the provider could not be reached or answered with an error
+	ErrProviderUnreachable = errors.New("provider unreachable")
+	//ErrBlocked the provider refused the request or answered with a bot challenge instead of the page
+	ErrBlocked = errors.New("provider blocked the request")
+	//ErrLayoutChanged the provider page no longer has the expected structure
+	ErrLayoutChanged = errors.New("provider page layout changed")
+	//ErrNumberNotFound the number is not saved or not offered by the provider
+	ErrNumberNotFound = errors.New("number not found")
+	//ErrDBCorrupt the DB file exists but cannot be de-serialized
+	ErrDBCorrupt = errors.New("DB corrupt")
+	//ErrUnknownProvider no provider is registered under the requested name
+	ErrUnknownProvider = errors.New("unknown provider")
+	//ErrInvalidNumber the text is not a plausible international phone number
+	ErrInvalidNumber = errors.New("invalid phone number")
+	//ErrDuplicateNumber the number is already saved
+	ErrDuplicateNumber = errors.New("number already saved")
+	//ErrWaitTimeout no matching message arrived before the wait ended
+	ErrWaitTimeout = errors.New("timed out waiting for a matching message")
+)
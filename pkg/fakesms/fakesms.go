@@ -0,0 +1,194 @@
+//Package fakesms scrapes public receive-SMS websites for temporary phone numbers
+//and the messages they receive, and keeps a local DB of the numbers in use.
+package fakesms
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+//Number A struct that represents a new number to be addeded
+type Number struct {
+	Country string `json:"country"`
+	//Number the E.164 form, see Normalize
+	Number string `json:"number"`
+	//Display the number as the provider printed it
+	Display string `json:"display,omitempty"`
+	//CountryCode the ISO 3166-1 alpha-2 code of the country, "" when it is not known
+	CountryCode string `json:"country_code,omitempty"`
+	//CallingCode the international calling code without the +, "" when it is not known
+	CallingCode string    `json:"calling_code,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	//CreatedAtText the created_at of an older DB file that could not be parsed, CreatedAt is then zero
+	CreatedAtText string `json:"created_at_text,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	//Status the outcome of the last health check, "" if it was never checked
+	Status NumberStatus `json:"status,omitempty"`
+	//CheckedAt when the last health check ran
+	CheckedAt time.Time `json:"checked_at"`
+	//LastMessageAt when the newest message seen by the last health check was received
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+//UnmarshalJSON accepts the "2006-01-02 15:04:05 Monday" created_at of older DB files. A created_at
+//that cannot be parsed is kept in CreatedAtText, so one odd entry does not make the DB unreadable
+func (n *Number) UnmarshalJSON(data []byte) error {
+	type plainNumber Number
+	aux := struct {
+		*plainNumber
+		CreatedAt string `json:"created_at"`
+	}{plainNumber: (*plainNumber)(n)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	n.CreatedAt = time.Time{}
+	if aux.CreatedAt != "" {
+		createdAt, err := ParseTime(aux.CreatedAt, time.Now())
+		if err != nil {
+			n.CreatedAtText = aux.CreatedAt
+			return nil
+		}
+		n.CreatedAt = createdAt
+	}
+	return nil
+}
+
+//MarshalJSON leaves out checked_at and last_message_at while they are zero
+func (n Number) MarshalJSON() ([]byte, error) {
+	type plainNumber Number
+	return marshalUnescaped(struct {
+		plainNumber
+		CheckedAt     *time.Time `json:"checked_at,omitempty"`
+		LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+	}{plainNumber(n), optionalTime(n.CheckedAt), optionalTime(n.LastMessageAt)})
+}
+
+//ProviderName the provider the number was saved from, old entries predate providers
+func (n *Number) ProviderName() string {
+	if n.Provider == "" {
+		return DefaultProvider
+	}
+	return n.Provider
+}
+
+//Message a struct which represents the message
+type Message struct {
+	Body string `json:"body"`
+	//CreatedAt when the message was received, resolved from CreatedAtText
+	CreatedAt time.Time `json:"created_at"`
+	//CreatedAtText the timestamp as the provider printed it, e.g. "3 minutes ago"
+	CreatedAtText string `json:"created_at_text,omitempty"`
+	Originator    string `json:"originator"`
+	ExtractedCode string `json:"extracted_code,omitempty"`
+	//Key the dedupe key, see MessageKey
+	Key string `json:"key,omitempty"`
+	//FetchedAt when the message was first fetched
+	FetchedAt time.Time `json:"fetched_at"`
+	//Read whether the message was marked as read
+	Read bool `json:"read"`
+}
+
+//UnmarshalJSON accepts stored messages of older versions, whose created_at was the provider
+//text. Relative phrases are resolved against fetched_at
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plainMessage Message
+	aux := struct {
+		*plainMessage
+		CreatedAt string `json:"created_at"`
+	}{plainMessage: (*plainMessage)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	m.CreatedAt = time.Time{}
+	if createdAt, err := time.Parse(time.RFC3339Nano, aux.CreatedAt); err == nil {
+		m.CreatedAt = createdAt
+		return nil
+	}
+
+	if m.CreatedAtText == "" {
+		m.CreatedAtText = aux.CreatedAt
+	}
+	reference := m.FetchedAt
+	if reference.IsZero() {
+		reference = time.Now()
+	}
+	//unparseable text stays available in CreatedAtText
+	m.CreatedAt, _ = ParseTime(aux.CreatedAt, reference)
+	return nil
+}
+
+//MarshalJSON leaves out fetched_at while it is zero
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plainMessage Message
+	return marshalUnescaped(struct {
+		plainMessage
+		FetchedAt *time.Time `json:"fetched_at,omitempty"`
+	}{plainMessage(m), optionalTime(m.FetchedAt)})
+}
+
+//optionalTime Returns nil for the zero time, so omitempty drops it
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+//marshalUnescaped json.Marshal without HTML escaping, the encoder that calls a MarshalJSON
+//escapes its output itself unless SetEscapeHTML(false) was set
+func marshalUnescaped(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buffer.Bytes(), "\n"), nil
+}
+
+//Numbers A list of Number type
+type Numbers []Number
+
+//Messages A list of Message type
+type Messages []Message
+
+//Find Returns the index of the number in the list or -1, numbers are compared in E.164 form
+func (n Numbers) Find(number string) int {
+	number = numberKey(number)
+	for idx, candidate := range n {
+		if numberKey(candidate.Number) == number {
+			return idx
+		}
+	}
+	return -1
+}
+
+//SortNewestFirst Orders the messages by CreatedAt, newest first, keeping the provider order for ties
+func (m Messages) SortNewestFirst() {
+	sort.SliceStable(m, func(i, j int) bool {
+		return m[i].CreatedAt.After(m[j].CreatedAt)
+	})
+}
+
+//ReceivedAt Returns CreatedAt, or FetchedAt when the provider timestamp could not be parsed
+func (m *Message) ReceivedAt() time.Time {
+	if m.CreatedAt.IsZero() {
+		return m.FetchedAt
+	}
+	return m.CreatedAt
+}
+
+//Since Keeps the messages received at or after t, see ReceivedAt
+func (m Messages) Since(t time.Time) Messages {
+	since := Messages{}
+	for _, message := range m {
+		if !message.ReceivedAt().Before(t) {
+			since = append(since, message)
+		}
+	}
+	return since
+}
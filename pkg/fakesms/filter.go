@@ -0,0 +1,44 @@
+package fakesms
+
+import (
+	"fmt"
+	"regexp"
+)
+
+//FilterMessages Keeps the messages whose body matches the regular expression
+func FilterMessages(pattern string, messages Messages) (Messages, error) {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression provided: %w", err)
+	}
+
+	filteredMessages := make([]Message, 0)
+	for _, message := range messages {
+		//check match
+		isMatch := r.MatchString(message.Body)
+		if isMatch {
+			filteredMessages = append(filteredMessages, message)
+		}
+	}
+
+	return Messages(filteredMessages), nil
+}
+
+//ExtractMatch Returns the first capture group of the pattern. Without groups the detected
+//code is preferred over the whole match
+func ExtractMatch(pattern *regexp.Regexp, message *Message) string {
+	if pattern.NumSubexp() == 0 && message.ExtractedCode != "" {
+		return message.ExtractedCode
+	}
+
+	match := pattern.FindStringSubmatch(message.Body)
+	if match == nil {
+		return ""
+	}
+	for _, group := range match[1:] {
+		if group != "" {
+			return group
+		}
+	}
+	return match[0]
+}
@@ -0,0 +1,155 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//NumberStatus The health of a saved number, see Client.CheckHealth
+type NumberStatus string
+
+//Number states recorded by Client.CheckHealth
+const (
+	//StatusActive the number received a message within the idle period
+	StatusActive NumberStatus = "active"
+	//StatusIdle the number page exists, but nothing arrived within the idle period
+	StatusIdle NumberStatus = "idle"
+	//StatusDead the provider no longer offers a page for the number
+	StatusDead NumberStatus = "dead"
+)
+
+//DefaultIdleAfter how long a number may go without messages before it counts as idle
+const DefaultIdleAfter = 72 * time.Hour
+
+//HealthReport The outcome of checking one saved number
+type HealthReport struct {
+	Number *Number `json:"number"`
+	//Replacement the number that took the place of a dead one when rotating
+	Replacement *Number `json:"replacement,omitempty"`
+}
+
+//CheckHealth Fetches the messages of a saved number and records whether it is active, idle
+//or dead. Errors other than a missing number page are returned without changing the number
+func (c *Client) CheckHealth(ctx context.Context, number string, idleAfter time.Duration) (*Number, error) {
+	saved, err := c.Store.GetNumber(number)
+	if err != nil {
+		return nil, err
+	}
+
+	checkedAt := time.Now()
+	status, lastMessageAt := StatusDead, saved.LastMessageAt
+	messages, _, err := c.Fetch(ctx, saved)
+	switch {
+	case err == nil:
+		for idx := range messages {
+			//a timestamp that could not be parsed says nothing about when the message arrived,
+			//ReceivedAt would take the fetch time for it
+			if receivedAt := messages[idx].CreatedAt; receivedAt.After(lastMessageAt) {
+				lastMessageAt = receivedAt
+			}
+		}
+		status = StatusIdle
+		if !lastMessageAt.IsZero() && checkedAt.Sub(lastMessageAt) <= idleAfter {
+			status = StatusActive
+		}
+	case !errors.Is(err, ErrNumberNotFound):
+		return nil, err
+	}
+
+	err = c.Store.UpdateNumber(number, func(n *Number) error {
+		n.Status, n.CheckedAt, n.LastMessageAt = status, checkedAt, lastMessageAt
+		*saved = *n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+//Rotate Replaces a saved number with one its provider currently offers, preferring the
+//same country. Numbers that are saved already are never picked
+func (c *Client) Rotate(ctx context.Context, number string) (*Number, error) {
+	old, err := c.Store.GetNumber(number)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := c.AvailableNumbers(ctx, old.ProviderName())
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := c.Store.ListNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	var replacement *Number
+	for idx := range available {
+		candidate := &available[idx]
+		if saved.Find(candidate.Number) != -1 {
+			continue
+		}
+		if sameCountry(candidate, old) {
+			replacement = candidate
+			break
+		}
+		if replacement == nil {
+			replacement = candidate
+		}
+	}
+	if replacement == nil {
+		return nil, fmt.Errorf("%w: %s offers no unused number to replace %s", ErrNumberNotFound, old.ProviderName(), number)
+	}
+
+	if err = c.Store.AddNumber(replacement); err != nil {
+		return nil, err
+	}
+	if err = c.Store.RemoveNumber(number); err != nil {
+		return nil, err
+	}
+	return replacement, nil
+}
+
+//sameCountry reports whether the candidate is from the country of the number, however the sites
+//spell it, e.g. "UK" and "United Kingdom", see Number.MatchesCountry
+func sameCountry(candidate, number *Number) bool {
+	if number.CountryCode != "" {
+		return candidate.MatchesCountry(number.CountryCode)
+	}
+	return number.Country != "" && candidate.MatchesCountry(number.Country)
+}
+
+//CheckAll Checks the health of the given saved numbers, or of all of them when none is
+//given, replacing dead ones when rotate is set
+func (c *Client) CheckAll(ctx context.Context, idleAfter time.Duration, rotate bool, numbers ...string) ([]HealthReport, error) {
+	if len(numbers) == 0 {
+		saved, err := c.Store.ListNumbers()
+		if err != nil {
+			return nil, err
+		}
+		for _, number := range saved {
+			numbers = append(numbers, number.Number)
+		}
+	}
+
+	reports := make([]HealthReport, 0, len(numbers))
+	for _, number := range numbers {
+		checked, err := c.CheckHealth(ctx, number, idleAfter)
+		if err != nil {
+			return reports, err
+		}
+
+		report := HealthReport{Number: checked}
+		if rotate && checked.Status == StatusDead {
+			if report.Replacement, err = c.Rotate(ctx, checked.Number); err != nil {
+				return reports, err
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
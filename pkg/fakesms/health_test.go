@@ -0,0 +1,81 @@
+package fakesms
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCheckAllRotatesDeadNumbers(t *testing.T) {
+	provider := registerTestProvider(t, "static")
+	client := newTestClient(t)
+	for _, number := range []Number{
+		{Number: "+4915735983768", Country: "Germany", Provider: "static"},
+		{Number: "+447700900123", Country: "United Kingdom", Provider: "static"},
+	} {
+		number := number
+		if err := client.Store.AddNumber(&number); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	provider.messages = []Message{
+		{Originator: "Google", Body: "G-482913", CreatedAt: time.Now().Add(-5 * time.Hour), CreatedAtText: "5 hours ago"},
+	}
+	provider.retired = map[string]bool{"+447700900123": true}
+	provider.numbers = []Number{
+		{Number: "+15005550006", Country: "United States", Provider: "static"},
+		//the site spells the country differently now
+		{Number: "+447700900456", Country: "UK", Provider: "static"},
+	}
+
+	reports, err := client.CheckAll(context.Background(), 2*time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+	if reports[0].Number.Status != StatusIdle || reports[0].Replacement != nil {
+		t.Errorf("expected the German number to be idle, got %+v", *reports[0].Number)
+	}
+	if reports[1].Number.Status != StatusDead {
+		t.Errorf("expected the retired number to be dead, got %s", reports[1].Number.Status)
+	}
+	if reports[1].Replacement == nil || reports[1].Replacement.Number != "+447700900456" {
+		t.Errorf("expected the retired number to be replaced from the same country, got %+v", reports[1].Replacement)
+	}
+
+	saved, err := client.Store.ListNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Find("+447700900123") != -1 || saved.Find("+447700900456") == -1 {
+		t.Errorf("expected the dead number to be swapped, saved %+v", saved)
+	}
+
+	checked, err := client.CheckHealth(context.Background(), "+4915735983768", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked.Status != StatusActive || checked.LastMessageAt.IsZero() {
+		t.Errorf("expected the German number to be active within a day, got %+v", checked)
+	}
+}
+
+func TestCheckHealthIgnoresUnparseableTimes(t *testing.T) {
+	provider := registerTestProvider(t, "static")
+	client := newTestClient(t)
+	if err := client.Store.AddNumber(&Number{Number: "+4915735983768", Provider: "static"}); err != nil {
+		t.Fatal(err)
+	}
+
+	provider.messages = []Message{{Originator: "Google", Body: "G-482913", CreatedAtText: "in the olden days"}}
+	checked, err := client.CheckHealth(context.Background(), "+4915735983768", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked.Status != StatusIdle || !checked.LastMessageAt.IsZero() {
+		t.Errorf("expected a message of unknown age to leave the number idle, got %+v", checked)
+	}
+}
@@ -0,0 +1,94 @@
+package fakesms
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+//testProvider a stand-in for a provider site, tests set what it offers and how it answers
+type testProvider struct {
+	name string
+	//numbers offered instead of the default German number
+	numbers []Number
+	//messages returned for every number
+	messages []Message
+	//retired numbers whose page is gone
+	retired map[string]bool
+	//err fails every request
+	err error
+	//slow answers the listing of numbers only when its context ends
+	slow bool
+	//script when set answers the nth fetch of messages instead of messages
+	script func(call int) ([]Message, error)
+
+	mutex sync.Mutex
+	calls int
+}
+
+func (p *testProvider) Name() string {
+	return p.name
+}
+
+func (p *testProvider) AvailableNumbers(ctx context.Context) ([]Number, error) {
+	if p.slow {
+		<-ctx.Done()
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnreachable, ctx.Err())
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.numbers != nil {
+		return append([]Number{}, p.numbers...), nil
+	}
+	return []Number{{Number: "+4915735983768", Country: "Germany"}}, nil
+}
+
+func (p *testProvider) Messages(ctx context.Context, number string) ([]Message, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	call := p.calls
+	p.calls++
+	switch {
+	case p.script != nil:
+		return p.script(call)
+	case p.err != nil:
+		return nil, p.err
+	case p.retired[number]:
+		return nil, fmt.Errorf("%w: %s", ErrNumberNotFound, number)
+	}
+	return append([]Message{}, p.messages...), nil
+}
+
+//registerTestProvider registers a testProvider under name until the test ends
+func registerTestProvider(t *testing.T, name string) *testProvider {
+	provider := &testProvider{name: name}
+	Register(name, func(options ProviderOptions) Provider {
+		return provider
+	})
+	t.Cleanup(func() {
+		Unregister(name)
+	})
+	return provider
+}
+
+//tempDir creates a directory that is removed when the test ends
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "fake-sms-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return dir
+}
+
+//newTestClient a client keeping its DB and provider state in a temporary directory
+func newTestClient(t *testing.T, options ...Option) *Client {
+	dir := tempDir(t)
+	return NewClient(append([]Option{WithStore(NewJSONStore(dir)), WithStateDir(dir)}, options...)...)
+}
@@ -0,0 +1,246 @@
+package fakesms
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//lastCheckMeta the metadata key holding when the messages of a number were last fetched
+const lastCheckMeta = "last_check:"
+
+//relativeTimeWords mark timestamps like "3 minutes ago" that change between fetches
+var relativeTimeWords = []string{"ago", "just now", "now"}
+
+//relativeTimeBucket the precision relative timestamps are kept at in the dedupe key
+const relativeTimeBucket = 10 * time.Minute
+
+//isRelativeTime tells timestamps that drift between fetches from absolute ones
+func isRelativeTime(normalized string) bool {
+	for _, word := range relativeTimeWords {
+		if strings.Contains(normalized, word) {
+			return true
+		}
+	}
+	return false
+}
+
+//normalizeText lower-cases a timestamp and collapses its whitespace
+func normalizeText(createdAt string) string {
+	return strings.ToLower(strings.Join(strings.Fields(createdAt), " "))
+}
+
+//normalizeTime reduces the timestamp of a message to the part that is stable between fetches.
+//Relative timestamps are taken from the resolved CreatedAt, rounded down to relativeTimeBucket
+func normalizeTime(message *Message) string {
+	normalized := normalizeText(message.CreatedAtText)
+	if !isRelativeTime(normalized) {
+		return normalized
+	}
+	if message.CreatedAt.IsZero() {
+		return ""
+	}
+	return message.CreatedAt.UTC().Truncate(relativeTimeBucket).Format(time.RFC3339)
+}
+
+//timeTolerance how far the resolved times of the same message may lie apart between fetches.
+//A relative timestamp is only as precise as its smallest unit, e.g. an hour for "2 hours ago"
+func timeTolerance(createdAtText string) time.Duration {
+	if !isRelativeTime(normalizeText(createdAtText)) {
+		return 0
+	}
+	precision := time.Minute
+	for idx, match := range relativePhrase.FindAllStringSubmatch(createdAtText, -1) {
+		if unit := relativeUnit(match[3]); unit != 0 && (idx == 0 || unit < precision) {
+			precision = unit
+		}
+	}
+	if tolerance := 2 * precision; tolerance > relativeTimeBucket {
+		return tolerance
+	}
+	return relativeTimeBucket
+}
+
+//MessageKey Returns the stable dedupe key of a message, built from the originator,
+//the body and the normalized provider timestamp, see normalizeTime
+func MessageKey(message *Message) string {
+	hash := sha1.New()
+	for _, part := range []string{message.Originator, message.Body, normalizeTime(message)} {
+		hash.Write([]byte(strings.TrimSpace(part)))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+//sameMessage tells whether a fetched message is the stored one, allowing for the drift of relative timestamps
+func sameMessage(stored, fetched *Message) bool {
+	if strings.TrimSpace(stored.Originator) != strings.TrimSpace(fetched.Originator) ||
+		strings.TrimSpace(stored.Body) != strings.TrimSpace(fetched.Body) {
+		return false
+	}
+	if stored.CreatedAt.IsZero() || fetched.CreatedAt.IsZero() {
+		//without a time to compare, relative timestamps cannot tell messages apart
+		storedText, fetchedText := normalizeText(stored.CreatedAtText), normalizeText(fetched.CreatedAtText)
+		return storedText == fetchedText || (isRelativeTime(storedText) && isRelativeTime(fetchedText))
+	}
+	drift := stored.CreatedAt.Sub(fetched.CreatedAt)
+	if drift < 0 {
+		drift = -drift
+	}
+	return drift <= timeTolerance(fetched.CreatedAtText)
+}
+
+//storeMessages merges fetched messages into the store. The fetched messages get their key,
+//their time resolved against fetchedAt and their read state filled in, the ones that were not
+//stored before are returned. Every stored message stands for at most one fetched message, so
+//the same text received twice is kept twice. Numbers that are not saved keep their messages
+//too, so messages --new and history also work for a number that was only looked up
+func (c *Client) storeMessages(number string, fetched Messages, fetchedAt time.Time) (Messages, error) {
+	fresh := Messages{}
+	err := c.Store.UpdateMessages(number, func(stored Messages) (Messages, error) {
+		known := make(map[string]*Message, len(stored))
+		for idx := range stored {
+			known[stored[idx].Key] = &stored[idx]
+		}
+		claimed := make(map[string]bool)
+
+		fresh = fresh[:0]
+		for idx := range fetched {
+			message := &fetched[idx]
+			if message.CreatedAt.IsZero() {
+				message.CreatedAt, _ = ParseTime(message.CreatedAtText, fetchedAt)
+			}
+			message.Key = MessageKey(message)
+
+			previous, exists := known[message.Key]
+			if !exists || claimed[message.Key] || !sameMessage(previous, message) {
+				//the bucket of a relative time may have moved on since the last fetch
+				previous = nil
+				for candidate := range stored {
+					if !claimed[stored[candidate].Key] && sameMessage(&stored[candidate], message) {
+						previous = &stored[candidate]
+						break
+					}
+				}
+			}
+			if previous != nil {
+				claimed[previous.Key] = true
+				message.Key, message.FetchedAt, message.Read = previous.Key, previous.FetchedAt, previous.Read
+				//relative timestamps are most precise when the message was new
+				if !previous.CreatedAt.IsZero() {
+					message.CreatedAt = previous.CreatedAt
+				}
+				continue
+			}
+
+			//the same text within the same bucket, e.g. a resent code
+			for occurrence := 2; known[message.Key] != nil; occurrence++ {
+				message.Key = fmt.Sprintf("%s-%d", MessageKey(message), occurrence)
+			}
+			message.FetchedAt = fetchedAt
+			known[message.Key] = message
+			claimed[message.Key] = true
+			fresh = append(fresh, *message)
+		}
+
+		//providers list the newest messages first
+		return append(append(Messages{}, fresh...), stored...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.Store.SetMeta(lastCheckMeta+numberKey(number), fetchedAt.Format(time.RFC3339)); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+//History Returns the stored messages of a number without contacting the provider, newest first
+func (c *Client) History(number string) (Messages, error) {
+	messages, err := c.Store.Messages(number)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = Messages{}
+	}
+	messages.SortNewestFirst()
+	return messages, nil
+}
+
+//LastCheck Returns when the messages of a number were last fetched, the zero time if never
+func (c *Client) LastCheck(number string) (time.Time, error) {
+	value, err := c.Store.Meta(lastCheckMeta + numberKey(number))
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+//MarkRead Sets the read state of the stored messages with the given keys, or of all
+//messages of the number when no key is given. Returns how many messages changed
+func (c *Client) MarkRead(number string, read bool, keys ...string) (int, error) {
+	selected := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		selected[key] = true
+	}
+
+	changed := 0
+	err := c.Store.UpdateMessages(number, func(stored Messages) (Messages, error) {
+		changed = 0
+		for idx := range stored {
+			if len(keys) > 0 && !selected[stored[idx].Key] {
+				continue
+			}
+			if stored[idx].Read != read {
+				stored[idx].Read = read
+				changed++
+			}
+		}
+		return stored, nil
+	})
+	return changed, err
+}
+
+//Unread Keeps the messages that are not marked as read
+func (m Messages) Unread() Messages {
+	unread := Messages{}
+	for _, message := range m {
+		if !message.Read {
+			unread = append(unread, message)
+		}
+	}
+	return unread
+}
+
+//PruneMessages Deletes the stored messages received before t, of one number or of all
+//saved numbers when number is "". Returns how many messages were deleted
+func (c *Client) PruneMessages(number string, before time.Time) (int, error) {
+	numbers := []string{number}
+	if number == "" {
+		saved, err := c.Store.ListNumbers()
+		if err != nil {
+			return 0, err
+		}
+		numbers = numbers[:0]
+		for _, savedNumber := range saved {
+			numbers = append(numbers, savedNumber.Number)
+		}
+	}
+
+	removed := 0
+	for _, current := range numbers {
+		err := c.Store.UpdateMessages(current, func(stored Messages) (Messages, error) {
+			kept := stored.Since(before)
+			removed += len(stored) - len(kept)
+			return kept, nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,133 @@
+package fakesms
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFetchStoresAndDedupesMessages(t *testing.T) {
+	provider := registerTestProvider(t, "static")
+	client := newTestClient(t)
+	number := &Number{Number: "+4915735983768", Provider: "static"}
+
+	provider.messages = []Message{
+		{Originator: "Google", Body: "G-482913 is your Google verification code.", CreatedAtText: "1 minute ago"},
+	}
+	_, fresh, err := client.Fetch(context.Background(), number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh) != 1 {
+		t.Fatalf("expected 1 new message, got %d", len(fresh))
+	}
+
+	//the same message with a drifted relative time and a newer one
+	provider.messages = []Message{
+		{Originator: "Telegram", Body: "Telegram code: 57201", CreatedAtText: "just now"},
+		{Originator: "Google", Body: "G-482913 is your Google verification code.", CreatedAtText: "3 minutes ago"},
+	}
+	_, fresh, err = client.Fetch(context.Background(), number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh) != 1 || fresh[0].Originator != "Telegram" {
+		t.Fatalf("expected only the Telegram message to be new, got %+v", fresh)
+	}
+
+	history, err := client.History(number.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Originator != "Telegram" {
+		t.Fatalf("expected 2 stored messages, newest first, got %+v", history)
+	}
+
+	changed, err := client.MarkRead(number.Number, true, history[1].Key)
+	if err != nil || changed != 1 {
+		t.Fatalf("expected to mark 1 message read, got %d, %v", changed, err)
+	}
+	history, _ = client.History(number.Number)
+	if unread := history.Unread(); len(unread) != 1 || unread[0].Originator != "Telegram" {
+		t.Fatalf("expected the Telegram message to stay unread, got %+v", unread)
+	}
+}
+
+func TestFetchKeepsRepeatedMessages(t *testing.T) {
+	provider := registerTestProvider(t, "static")
+	client := newTestClient(t)
+	number := &Number{Number: "+4915735983768", Provider: "static"}
+
+	const body = "Your code is 1234"
+	provider.messages = []Message{{Originator: "Acme", Body: body, CreatedAtText: "25 minutes ago"}}
+	if _, _, err := client.Fetch(context.Background(), number); err != nil {
+		t.Fatal(err)
+	}
+
+	//the same text again, the earlier one has aged a little
+	provider.messages = []Message{
+		{Originator: "Acme", Body: body, CreatedAtText: "just now"},
+		{Originator: "Acme", Body: body, CreatedAtText: "27 minutes ago"},
+	}
+	_, fresh, err := client.Fetch(context.Background(), number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh) != 1 || fresh[0].CreatedAtText != "just now" {
+		t.Fatalf("expected only the resent message to be new, got %+v", fresh)
+	}
+
+	//two rows with the same text and time are two messages as well
+	provider.messages = []Message{
+		{Originator: "Acme", Body: body, CreatedAtText: "just now"},
+		{Originator: "Acme", Body: body, CreatedAtText: "1 minute ago"},
+		{Originator: "Acme", Body: body, CreatedAtText: "28 minutes ago"},
+	}
+	if _, fresh, err = client.Fetch(context.Background(), number); err != nil || len(fresh) != 1 {
+		t.Fatalf("expected the third copy to be new, got %+v, %v", fresh, err)
+	}
+
+	history, err := client.History(number.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]bool)
+	for _, message := range history {
+		keys[message.Key] = true
+	}
+	if len(history) != 3 || len(keys) != 3 {
+		t.Fatalf("expected 3 stored messages with distinct keys, got %+v", history)
+	}
+	if age := time.Since(history[2].CreatedAt); age < 24*time.Minute || age > 26*time.Minute {
+		t.Errorf("expected the oldest message to keep the time of its first fetch, got %s ago", age)
+	}
+}
+
+func TestRemoveNumberDropsHistory(t *testing.T) {
+	provider := registerTestProvider(t, "static")
+	provider.messages = []Message{{Originator: "Acme", Body: "Your code is 4242", CreatedAtText: "just now"}}
+
+	for _, store := range []Store{NewJSONStore(tempDir(t)), NewBoltStore(tempDir(t))} {
+		client := newTestClient(t, WithStore(store))
+		number := &Number{Number: "+4915735983768", Provider: "static"}
+		if err := store.AddNumber(number); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := client.Fetch(context.Background(), number); err != nil {
+			t.Fatal(err)
+		}
+		if lastCheck, err := client.LastCheck(number.Number); err != nil || lastCheck.IsZero() {
+			t.Fatalf("%T: expected the fetch to be recorded, got %s, %v", store, lastCheck, err)
+		}
+
+		if err := store.RemoveNumber(number.Number); err != nil {
+			t.Fatal(err)
+		}
+		if history, err := client.History(number.Number); err != nil || len(history) != 0 {
+			t.Errorf("%T: expected the messages to be removed, got %+v, %v", store, history, err)
+		}
+		if lastCheck, err := client.LastCheck(number.Number); err != nil || !lastCheck.IsZero() {
+			t.Errorf("%T: expected the last check to be removed, got %s, %v", store, lastCheck, err)
+		}
+	}
+}
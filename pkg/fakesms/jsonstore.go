@@ -0,0 +1,363 @@
+package fakesms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	dbFileName     = "db.json"
+	lockFileName   = "db.lock"
+	backupFileName = "db.json.bak"
+)
+
+//JSONStore The JSON file store, it keeps everything in <Dir>/db.json.
+//Every operation holds an advisory lock on <Dir>/db.lock and files are replaced
+//atomically, so several processes can share the same directory
+type JSONStore struct {
+	//Dir the directory holding the DB file, DefaultDBDir() when empty
+	Dir string
+}
+
+//jsonDocument the content of db.json, older versions stored only the numbers array
+type jsonDocument struct {
+	Numbers  Numbers             `json:"numbers"`
+	Messages map[string]Messages `json:"messages,omitempty"`
+	Meta     map[string]string   `json:"meta,omitempty"`
+}
+
+//NewJSONStore Creates a store in dir, an empty dir selects DefaultDBDir()
+func NewJSONStore(dir string) *JSONStore {
+	return &JSONStore{Dir: dir}
+}
+
+//DefaultDBDir Returns $FAKE_SMS_DB_DIR, or $HOME/.fake-sms if it is not set
+func DefaultDBDir() string {
+	dbPath, exists := os.LookupEnv("FAKE_SMS_DB_DIR")
+	if !exists {
+		dbPath = os.Getenv("HOME")
+		dbPath = filepath.Join(dbPath, ".fake-sms")
+	}
+	return dbPath
+}
+
+func (d *JSONStore) dir() string {
+	if d.Dir == "" {
+		return DefaultDBDir()
+	}
+	return d.Dir
+}
+
+//Path Returns the path of the DB file
+func (d *JSONStore) Path() string {
+	return filepath.Join(d.dir(), dbFileName)
+}
+
+//writeFileAtomic replaces path with data by writing a temporary file next to it and renaming it
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	//a no-op once the rename succeeded
+	defer os.Remove(temp.Name())
+
+	if _, err = temp.Write(data); err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp.Name(), perm)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+//lock takes the DB lock and makes sure the DB file exists, call unlock when done
+func (d *JSONStore) lock() (unlock func(), err error) {
+	/*
+		The DB will be created at <db_dir>/db.json
+		If the DB does not exist, it will be created and will be
+		initialized to an empty document
+	*/
+
+	dbDir := d.dir()
+	if err = os.MkdirAll(dbDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create DB directory at %s: %w", dbDir, err)
+	}
+
+	lockPath := filepath.Join(dbDir, lockFileName)
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB lock %s: %w", lockPath, err)
+	}
+	if err = lockFile(lock); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock DB %s: %w", lockPath, err)
+	}
+	unlock = func() {
+		unlockFile(lock)
+		lock.Close()
+	}
+
+	dbPath := d.Path()
+	_, err = os.Stat(dbPath)
+	if os.IsNotExist(err) {
+		emptyDocument := []byte("{\"numbers\": []}\n")
+		err = writeFileAtomic(dbPath, emptyDocument, 0600)
+		if err != nil {
+			unlock()
+			return nil, fmt.Errorf("failed to create DB file at %s: %w", dbPath, err)
+		}
+	}
+
+	return unlock, nil
+}
+
+//decodeDocument de-serializes DB content, an empty file counts as corrupt
+func decodeDocument(data []byte) (*jsonDocument, error) {
+	document := &jsonDocument{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		//the numbers array written by older versions
+		if err := json.Unmarshal(trimmed, &document.Numbers); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, document); err != nil {
+		return nil, err
+	}
+
+	if document.Numbers == nil {
+		document.Numbers = Numbers{}
+	}
+	for idx := range document.Numbers {
+		//numbers that are not valid are kept as they are
+		document.Numbers[idx].Normalize()
+	}
+
+	//older versions kept the messages under the number as the provider printed it
+	messages := make(map[string]Messages, len(document.Messages))
+	for number, stored := range document.Messages {
+		key := numberKey(number)
+		messages[key] = append(messages[key], stored...)
+	}
+	document.Messages = messages
+	if document.Meta == nil {
+		document.Meta = make(map[string]string)
+	}
+	return document, nil
+}
+
+//read reads the DB file, must be called with the lock held
+func (d *JSONStore) read() (*jsonDocument, error) {
+	dbPath := d.Path()
+	data, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DB file at %s: %w", dbPath, err)
+	}
+
+	document, err := decodeDocument(data)
+	if err != nil {
+		return d.recover(err)
+	}
+
+	return document, nil
+}
+
+//recover moves a corrupt DB file aside and restores the last good copy, or starts empty
+func (d *JSONStore) recover(cause error) (*jsonDocument, error) {
+	dbPath := d.Path()
+	corruptPath := fmt.Sprintf("%s.corrupt-%s", dbPath, time.Now().Format("20060102-150405"))
+	if err := os.Rename(dbPath, corruptPath); err != nil {
+		return nil, fmt.Errorf("%w: failed to de-serialize DB file %s: %s", ErrDBCorrupt, dbPath, cause)
+	}
+
+	document, _ := decodeDocument([]byte("{}"))
+	backupPath := filepath.Join(d.dir(), backupFileName)
+	if data, err := ioutil.ReadFile(backupPath); err == nil {
+		if backup, err := decodeDocument(data); err == nil {
+			document = backup
+		}
+	}
+
+	log.Printf(
+		"DB file %s was corrupt (%s), moved it to %s and restored %d number(s)\n",
+		dbPath, cause, corruptPath, len(document.Numbers),
+	)
+
+	if err := d.write(document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+//write atomically replaces the DB file and keeps a copy as backup, must be called with the lock held
+func (d *JSONStore) write(document *jsonDocument) error {
+	dbPath := d.Path()
+	data, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("failed to serialize DB file %s: %w", dbPath, err)
+	}
+
+	err = writeFileAtomic(dbPath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to save DB file %s: %w", dbPath, err)
+	}
+
+	//the backup only matters for recovery, failing to write it is not fatal
+	writeFileAtomic(filepath.Join(d.dir(), backupFileName), data, 0600)
+	return nil
+}
+
+//view reads the document under the DB lock
+func (d *JSONStore) view() (*jsonDocument, error) {
+	unlock, err := d.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return d.read()
+}
+
+//update runs a read-modify-write cycle under the DB lock
+func (d *JSONStore) update(modify func(*jsonDocument) error) error {
+	unlock, err := d.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	document, err := d.read()
+	if err != nil {
+		return err
+	}
+
+	if err = modify(document); err != nil {
+		return err
+	}
+
+	//write it back to the db
+	return d.write(document)
+}
+
+//AddNumber implements Store
+func (d *JSONStore) AddNumber(number *Number) error {
+	if err := number.Normalize(); err != nil {
+		return err
+	}
+	return d.update(func(document *jsonDocument) error {
+		if document.Numbers.Find(number.Number) != -1 {
+			return fmt.Errorf("%w: %s", ErrDuplicateNumber, number.Number)
+		}
+		document.Numbers = append(document.Numbers, *number)
+		return nil
+	})
+}
+
+//ListNumbers implements Store
+func (d *JSONStore) ListNumbers() (Numbers, error) {
+	document, err := d.view()
+	if err != nil {
+		return nil, err
+	}
+	return document.Numbers, nil
+}
+
+//GetNumber implements Store
+func (d *JSONStore) GetNumber(number string) (*Number, error) {
+	numbers, err := d.ListNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := numbers.Find(number)
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: %s is not saved", ErrNumberNotFound, number)
+	}
+	return &numbers[idx], nil
+}
+
+//UpdateNumber implements Store
+func (d *JSONStore) UpdateNumber(number string, modify func(*Number) error) error {
+	return d.update(func(document *jsonDocument) error {
+		idx := document.Numbers.Find(number)
+		if idx == -1 {
+			return fmt.Errorf("%w: %s is not saved", ErrNumberNotFound, number)
+		}
+		return modify(&document.Numbers[idx])
+	})
+}
+
+//RemoveNumber implements Store
+func (d *JSONStore) RemoveNumber(number string) error {
+	return d.update(func(document *jsonDocument) error {
+		idx := document.Numbers.Find(number)
+		if idx == -1 {
+			return fmt.Errorf("%w: %s is not saved", ErrNumberNotFound, number)
+		}
+
+		document.Numbers = append(document.Numbers[:idx], document.Numbers[idx+1:]...)
+		delete(document.Messages, numberKey(number))
+		delete(document.Meta, lastCheckMeta+numberKey(number))
+		return nil
+	})
+}
+
+//Messages implements Store
+func (d *JSONStore) Messages(number string) (Messages, error) {
+	document, err := d.view()
+	if err != nil {
+		return nil, err
+	}
+	return document.Messages[numberKey(number)], nil
+}
+
+//UpdateMessages implements Store
+func (d *JSONStore) UpdateMessages(number string, modify func(Messages) (Messages, error)) error {
+	number = numberKey(number)
+	return d.update(func(document *jsonDocument) error {
+		messages, err := modify(document.Messages[number])
+		if err != nil {
+			return err
+		}
+
+		if len(messages) == 0 {
+			delete(document.Messages, number)
+		} else {
+			document.Messages[number] = messages
+		}
+		return nil
+	})
+}
+
+//Meta implements Store
+func (d *JSONStore) Meta(key string) (string, error) {
+	document, err := d.view()
+	if err != nil {
+		return "", err
+	}
+	return document.Meta[key], nil
+}
+
+//SetMeta implements Store
+func (d *JSONStore) SetMeta(key, value string) error {
+	return d.update(func(document *jsonDocument) error {
+		if value == "" {
+			delete(document.Meta, key)
+		} else {
+			document.Meta[key] = value
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package fakesms
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestJSONStoreConcurrentAdd(t *testing.T) {
+	dir := tempDir(t)
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			//every writer uses its own DB value, like separate processes would
+			db := NewJSONStore(dir)
+			if err := db.AddNumber(&Number{Number: fmt.Sprintf("+4915000000%02d", i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	numbers, err := NewJSONStore(dir).ListNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != writers {
+		t.Fatalf("expected %d numbers, got %d", writers, len(numbers))
+	}
+}
+
+func TestJSONStoreRecoversFromTruncatedFile(t *testing.T) {
+	dir := tempDir(t)
+
+	db := NewJSONStore(dir)
+	for _, number := range []string{"+4915000000001", "+4915000000002"} {
+		if err := db.AddNumber(&Number{Number: number}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//simulate a crash half way through a write
+	if err := ioutil.WriteFile(db.Path(), []byte(`[{"number":"+49150`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	numbers, err := db.ListNumbers()
+	if err != nil {
+		t.Fatalf("expected recovery, got %s", err)
+	}
+	if len(numbers) != 2 {
+		t.Fatalf("expected the backup with 2 numbers, got %d", len(numbers))
+	}
+
+	corrupt, _ := filepath.Glob(filepath.Join(dir, "db.json.corrupt-*"))
+	if len(corrupt) != 1 {
+		t.Fatalf("expected the corrupt file to be kept aside, found %v", corrupt)
+	}
+}
+
+func TestJSONStoreKeepsUnparseableCreatedAt(t *testing.T) {
+	dir := tempDir(t)
+
+	//the numbers array written by older versions, one of them with a date in a format nobody expects
+	legacy := `[{"number":"+4915000000001","created_at":"2020-10-10 10:10:10 Saturday"},` +
+		`{"number":"+4915000000002","created_at":"Sat Oct 10 10:10:10 CEST 2020"}]`
+	db := NewJSONStore(dir)
+	if err := ioutil.WriteFile(db.Path(), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	numbers, err := db.ListNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(numbers) != 2 || numbers[0].CreatedAt.IsZero() {
+		t.Fatalf("expected both numbers, got %+v", numbers)
+	}
+	if !numbers[1].CreatedAt.IsZero() || numbers[1].CreatedAtText != "Sat Oct 10 10:10:10 CEST 2020" {
+		t.Errorf("expected the raw created_at to be kept, got %+v", numbers[1])
+	}
+	if corrupt, _ := filepath.Glob(filepath.Join(dir, "db.json.corrupt-*")); len(corrupt) != 0 {
+		t.Errorf("expected the DB not to be treated as corrupt, found %v", corrupt)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package fakesms
+
+import "os"
+
+//lockFile is a no-op where advisory locks are not available, writes stay atomic
+func lockFile(file *os.File) error {
+	return nil
+}
+
+//unlockFile is a no-op where advisory locks are not available
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build windows
+// +build windows
+
+package fakesms
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+//lockfileExclusiveLock the LOCKFILE_EXCLUSIVE_LOCK flag of LockFileEx
+const lockfileExclusiveLock = 0x2
+
+//lockFile takes an exclusive lock on the open file, blocking until it is free
+func lockFile(file *os.File) error {
+	overlapped := new(syscall.Overlapped)
+	result, _, err := procLockFileEx.Call(
+		file.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(overlapped)),
+	)
+	if result == 0 {
+		return err
+	}
+	return nil
+}
+
+//unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) error {
+	overlapped := new(syscall.Overlapped)
+	result, _, err := procUnlockFileEx.Call(
+		file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(overlapped)),
+	)
+	if result == 0 {
+		return err
+	}
+	return nil
+}
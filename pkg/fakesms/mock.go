@@ -0,0 +1,134 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//MockProvider The name of the provider serving locally injected messages
+const MockProvider = "mock"
+
+//mockDirName the directory below the state dir holding the inbox of the mock provider
+const mockDirName = "mock"
+
+//mockNumbers the numbers the mock provider always offers, besides the ones messages were sent to
+var mockNumbers = []Number{
+	{Number: "+15005550006", Country: "United States"},
+	{Number: "+447700900123", Country: "United Kingdom"},
+	{Number: "+4915735983768", Country: "Germany"},
+}
+
+//Mock A provider for offline development and tests. It offers a few fixed numbers and serves
+//the messages injected with Send, which are kept in a Store of their own
+type Mock struct {
+	inbox Store
+}
+
+func init() {
+	Register(MockProvider, func(options ProviderOptions) Provider {
+		dir := options.StateDir
+		if dir == "" {
+			dir = DefaultDBDir()
+		}
+		return NewMock(NewJSONStore(filepath.Join(dir, mockDirName)))
+	})
+}
+
+//NewMock Creates the mock provider, inbox keeps the numbers messages were sent to and the messages
+func NewMock(inbox Store) *Mock {
+	return &Mock{inbox: inbox}
+}
+
+//Name implements Provider
+func (m *Mock) Name() string {
+	return MockProvider
+}
+
+//AvailableNumbers implements Provider
+func (m *Mock) AvailableNumbers(ctx context.Context) ([]Number, error) {
+	numbers := make([]Number, 0, len(mockNumbers))
+	for _, number := range mockNumbers {
+		number.Provider, number.CreatedAt = MockProvider, time.Now()
+		numbers = append(numbers, number)
+	}
+
+	received, err := m.inbox.ListNumbers()
+	if err != nil {
+		return nil, err
+	}
+	for _, number := range received {
+		if Numbers(numbers).Find(number.Number) == -1 {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers, nil
+}
+
+//Messages implements Provider, newest first
+func (m *Mock) Messages(ctx context.Context, number string) ([]Message, error) {
+	normalized, err := NormalizeNumber(number)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = m.inbox.GetNumber(normalized); err != nil {
+		if !errors.Is(err, ErrNumberNotFound) || Numbers(mockNumbers).Find(normalized) == -1 {
+			return nil, err
+		}
+	}
+
+	messages, err := m.inbox.Messages(normalized)
+	if err != nil {
+		return nil, err
+	}
+	return []Message(messages), nil
+}
+
+//Send Injects a message from sender to the number, which the mock offers from then on
+func (m *Mock) Send(to, from, body string, at time.Time) (*Message, error) {
+	number := &Number{Number: to, Provider: MockProvider, CreatedAt: at}
+	if err := number.Normalize(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(body) == "" {
+		return nil, fmt.Errorf("the message body is empty")
+	}
+	if idx := Numbers(mockNumbers).Find(number.Number); idx != -1 {
+		number.Country = mockNumbers[idx].Country
+	}
+	if err := m.inbox.AddNumber(number); err != nil && !errors.Is(err, ErrDuplicateNumber) {
+		return nil, err
+	}
+
+	message := Message{
+		Originator: from,
+		Body:       body,
+		CreatedAt:  at,
+		//part of the key, so the same text sent twice is two messages
+		CreatedAtText: at.Format(time.RFC3339Nano),
+	}
+	message.Key = MessageKey(&message)
+	err := m.inbox.UpdateMessages(number.Number, func(messages Messages) (Messages, error) {
+		return append(Messages{message}, messages...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+//Mock Returns the mock provider of the client, for injecting messages
+func (c *Client) Mock() (*Mock, error) {
+	provider, err := c.Provider(MockProvider)
+	if err != nil {
+		return nil, err
+	}
+	mock, ok := provider.(*Mock)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not the mock provider", ErrUnknownProvider, MockProvider)
+	}
+	return mock, nil
+}
@@ -0,0 +1,59 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMockProvider(t *testing.T) {
+	client := newTestClient(t, WithDefaultProvider(MockProvider))
+	mock, err := client.Mock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mock.Send("+33 6 12 34 56 78", "Acme", "Your code is 271828", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	//numbers messages were sent to are offered next to the fixed ones
+	number, err := client.AvailableNumber(context.Background(), "", "+33612345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Store.AddNumber(number); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		//providers built later share the inbox
+		other, _ := client.Mock()
+		other.Send(number.Number, "Acme", "Your code is 314159", time.Now())
+	}()
+	code, err := client.WaitForOTP(ctx, number, WaitOptions{Interval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "314159" {
+		t.Errorf("expected the code sent during the wait, got %q", code)
+	}
+
+	history, err := client.History(number.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].ExtractedCode != "314159" {
+		t.Errorf("expected both messages in the history, newest first, got %v", history)
+	}
+
+	if _, err = mock.Messages(context.Background(), "+491701234567"); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("expected a number without messages to be unknown, got %v", err)
+	}
+	if messages, err := mock.Messages(context.Background(), "+15005550006"); err != nil || len(messages) != 0 {
+		t.Errorf("expected no messages for a fixed number, got %v %v", messages, err)
+	}
+}
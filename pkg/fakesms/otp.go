@@ -0,0 +1,92 @@
+package fakesms
+
+import (
+	"regexp"
+	"strings"
+)
+
+//codeKeywords words that usually sit next to a verification code, in several languages
+var codeKeywords = regexp.MustCompile(`(?i)` + strings.Join([]string{
+	`code`, `otp`, `pin`, `passcode`, `password`, `verif\w*`, `token`, `one[- ]time`,
+	`c[oó]digo`, `codice`, `kod`, `kode`, `kodu`, `mã`, `код\w*`, `пароль`,
+	`验证码`, `驗證碼`, `校验码`, `認証コード`, `確認コード`, `인증번호`, `رمز`, `קוד`,
+}, "|"))
+
+//codeCandidate matches runs of digits, optionally split into groups by dashes or spaces
+//and optionally prefixed by a short tag such as the G- used by Google
+var codeCandidate = regexp.MustCompile(`(?:\b([A-Z]{1,3})-)?\b(\d{2,4}(?:[- ]\d{2,4})+|\d+)\b`)
+
+const (
+	//minCodeDigits the fewest digits a code has
+	minCodeDigits = 4
+	//maxCodeDigits the most digits a code has, longer runs are phone or account numbers
+	maxCodeDigits = 8
+)
+
+//alphanumericCode matches mixed letter and digit codes right after a keyword, e.g. "code: AB12CD"
+var alphanumericCode = regexp.MustCompile(`(?i)(?:code|otp|token|c[oó]digo)\s*(?:is|est|ist|es|è|:|=)?\s*:?\s*([A-Z0-9]*\d[A-Z0-9]*)\b`)
+
+const (
+	//keywordWindowBefore how far before a candidate a keyword may appear to count as related
+	keywordWindowBefore = 40
+	//keywordWindowAfter how far after a candidate a keyword may appear to count as related
+	keywordWindowAfter = 30
+)
+
+//ExtractCode Finds the most likely one-time code in a message body, returns "" if there is none
+func ExtractCode(body string) string {
+	keywords := codeKeywords.FindAllStringIndex(body, -1)
+
+	best, bestScore := "", -1
+	for _, match := range codeCandidate.FindAllStringSubmatchIndex(body, -1) {
+		start, end := match[0], match[1]
+		code := strings.NewReplacer("-", "", " ", "").Replace(body[match[4]:match[5]])
+		if len(code) < minCodeDigits || len(code) > maxCodeDigits {
+			continue
+		}
+		score := 0
+		if nearKeyword(keywords, start, end) {
+			score += 2
+		}
+		if match[2] != -1 {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = code, score
+		}
+	}
+
+	if bestScore >= 2 {
+		return best
+	}
+
+	//codes mixing letters and digits are only trusted right after a keyword
+	if match := alphanumericCode.FindStringSubmatch(body); match != nil {
+		if length := len(match[1]); length >= minCodeDigits && length <= maxCodeDigits {
+			return strings.ToUpper(match[1])
+		}
+	}
+
+	//numbers far from any keyword are more likely phone numbers, prices or dates
+	return ""
+}
+
+func nearKeyword(keywords [][]int, start, end int) bool {
+	for _, keyword := range keywords {
+		if keyword[1] <= start && start-keyword[1] <= keywordWindowBefore {
+			return true
+		}
+		if keyword[0] >= end && keyword[0]-end <= keywordWindowAfter {
+			return true
+		}
+	}
+	return false
+}
+
+//extractCodes fills in the ExtractedCode of every message
+func extractCodes(messages Messages) Messages {
+	for idx := range messages {
+		messages[idx].ExtractedCode = ExtractCode(messages[idx].Body)
+	}
+	return messages
+}
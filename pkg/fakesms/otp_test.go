@@ -0,0 +1,43 @@
+package fakesms
+
+import "testing"
+
+func TestExtractCode(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"google prefix", "G-482913 is your Google verification code.", "482913"},
+		{"whatsapp dashed", "Your WhatsApp code: 123-456\nYou can also tap on this link to verify your phone: v.whatsapp.com/123456", "123456"},
+		{"telegram", "Telegram code: 57201\n\nYou can also tap on this link to log in:\nhttps://t.me/login/57201", "57201"},
+		{"your code is", "Your verification code is 8841. It expires in 10 minutes.", "8841"},
+		{"code after number", "739204 is your Instagram code. Don't share it.", "739204"},
+		{"spaced", "Your Microsoft account security code is 443 912", "443912"},
+		{"eight digits", "Use 20481632 as your one-time password for Example Bank", "20481632"},
+		{"alphanumeric", "Your login code: AB12CD", "AB12CD"},
+		{"otp label", "OTP 5521 for txn of INR 1500.00 at AMAZON. Valid for 3 mins.", "5521"},
+		{"spanish", "Tu código de verificación es 614203", "614203"},
+		{"portuguese", "Seu código do Uber é 7731. Não responda.", "7731"},
+		{"german", "Ihr Bestätigungscode lautet: 904512", "904512"},
+		{"french", "Votre code de vérification est 330187", "330187"},
+		{"italian", "Il tuo codice di verifica è 55810", "55810"},
+		{"russian", "Код подтверждения: 2468. Никому не сообщайте его.", "2468"},
+		{"chinese", "【淘宝】验证码 836401，您正在登录，请勿泄露。", "836401"},
+		{"japanese", "認証コード：195732 このコードは10分間有効です。", "195732"},
+		{"korean", "[Web발신] 인증번호 [482019]를 입력해주세요.", "482019"},
+		{"turkish", "Doğrulama kodunuz: 7390", "7390"},
+		{"phone number is not a code", "Call us at +4915735983768 for help", ""},
+		{"no code", "Hello! Your parcel has been delivered.", ""},
+		{"dashed groups", "Your verification code is: 12-34-56", "123456"},
+		{"too many digits", "Your code: 123 456 789", ""},
+		{"phone number in a text", "Hi, call me at 555 1234 tomorrow", ""},
+		{"number without keyword", "Welcome to Example. 8271", ""},
+	}
+
+	for _, c := range cases {
+		if got := ExtractCode(c.body); got != c.want {
+			t.Errorf("%s: ExtractCode(%q) = %q, want %q", c.name, c.body, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package fakesms
+
+import (
+	"fmt"
+	"strings"
+)
+
+//E.164 allows at most 15 digits, the shortest numbers in use have 7
+const (
+	minNumberDigits = 7
+	maxNumberDigits = 15
+)
+
+//callingCodes maps ISO 3166-1 alpha-2 codes to international calling codes
+var callingCodes = map[string]string{
+	"AD": "376", "AE": "971", "AF": "93", "AL": "355", "AM": "374", "AO": "244",
+	"AR": "54", "AT": "43", "AU": "61", "AZ": "994", "BA": "387", "BD": "880",
+	"BE": "32", "BG": "359", "BH": "973", "BO": "591", "BR": "55", "BS": "1",
+	"BY": "375", "CA": "1", "CH": "41", "CL": "56", "CM": "237", "CN": "86",
+	"CO": "57", "CR": "506", "CY": "357", "CZ": "420", "DE": "49", "DK": "45",
+	"DO": "1", "DZ": "213", "EC": "593", "EE": "372", "EG": "20", "ES": "34",
+	"ET": "251", "FI": "358", "FR": "33", "GB": "44", "GE": "995", "GH": "233",
+	"GR": "30", "GT": "502", "HK": "852", "HR": "385", "HU": "36", "ID": "62",
+	"IE": "353", "IL": "972", "IN": "91", "IQ": "964", "IR": "98", "IS": "354",
+	"IT": "39", "JM": "1", "JO": "962", "JP": "81", "KE": "254", "KG": "996",
+	"KH": "855", "KR": "82", "KW": "965", "KZ": "7", "LB": "961", "LK": "94",
+	"LT": "370", "LU": "352", "LV": "371", "MA": "212", "MD": "373", "ME": "382",
+	"MK": "389", "MM": "95", "MN": "976", "MO": "853", "MT": "356", "MX": "52",
+	"MY": "60", "NG": "234", "NL": "31", "NO": "47", "NP": "977", "NZ": "64",
+	"OM": "968", "PA": "507", "PE": "51", "PH": "63", "PK": "92", "PL": "48",
+	"PR": "1", "PT": "351", "PY": "595", "QA": "974", "RO": "40", "RS": "381",
+	"RU": "7", "SA": "966", "SE": "46", "SG": "65", "SI": "386", "SK": "421",
+	"TH": "66", "TJ": "992", "TN": "216", "TR": "90", "TW": "886", "TZ": "255",
+	"UA": "380", "UG": "256", "US": "1", "UY": "598", "UZ": "998", "VE": "58",
+	"VN": "84", "YE": "967", "ZA": "27", "ZW": "263",
+}
+
+//callingCodeRegions maps each calling code to the ISO codes sharing it
+var callingCodeRegions = make(map[string][]string)
+
+func init() {
+	for region, code := range callingCodes {
+		callingCodeRegions[code] = append(callingCodeRegions[code], region)
+	}
+}
+
+//NormalizeNumber Returns the E.164 form of an international number, e.g. "+4915735983768" for
+//"+49 157-3598 3768". A leading 00 is read as +, a missing + is assumed. ErrInvalidNumber
+//when the text is not a plausible number
+func NormalizeNumber(number string) (string, error) {
+	trimmed := strings.TrimSpace(number)
+	if strings.HasPrefix(trimmed, "00") {
+		trimmed = "+" + trimmed[2:]
+	}
+
+	digits := make([]rune, 0, len(trimmed))
+	for idx, r := range trimmed {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = append(digits, r)
+		case r == '+' && idx == 0:
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == '\u00a0' || r == '\t':
+		default:
+			return "", fmt.Errorf("%w: %q contains %q", ErrInvalidNumber, number, r)
+		}
+	}
+
+	if len(digits) < minNumberDigits || len(digits) > maxNumberDigits {
+		return "", fmt.Errorf("%w: %q has %d digits, expected %d to %d", ErrInvalidNumber, number, len(digits), minNumberDigits, maxNumberDigits)
+	}
+	if digits[0] == '0' {
+		return "", fmt.Errorf("%w: %q has no country calling code", ErrInvalidNumber, number)
+	}
+	return "+" + string(digits), nil
+}
+
+//numberKey the form numbers are saved and looked up under, the E.164 form when the number is valid
+func numberKey(number string) string {
+	if normalized, err := NormalizeNumber(number); err == nil {
+		return normalized
+	}
+	return strings.TrimSpace(number)
+}
+
+//CallingCode Returns the country calling code of an E.164 number without the +, "" when it is not known
+func CallingCode(number string) string {
+	digits := strings.TrimPrefix(number, "+")
+	//calling codes are prefix free, so at most one of the prefixes matches
+	for length := 1; length <= 3 && length <= len(digits); length++ {
+		if _, exists := callingCodeRegions[digits[:length]]; exists {
+			return digits[:length]
+		}
+	}
+	return ""
+}
+
+//Normalize Rewrites Number to its E.164 form, keeping the text the provider printed in Display,
+//and fills in CountryCode and CallingCode
+func (n *Number) Normalize() error {
+	normalized, err := NormalizeNumber(n.Number)
+	if err != nil {
+		return err
+	}
+
+	if n.Display == "" {
+		n.Display = strings.Join(strings.Fields(n.Number), " ")
+	}
+	n.Number = normalized
+	n.CallingCode = CallingCode(normalized)
+	if n.CountryCode == "" {
+		n.CountryCode = CountryCode(n.Country)
+	}
+	//a calling code shared by several countries, like +1, does not tell the country
+	if regions := callingCodeRegions[n.CallingCode]; n.CountryCode == "" && len(regions) == 1 {
+		n.CountryCode = regions[0]
+	}
+	return nil
+}
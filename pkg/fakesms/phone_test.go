@@ -0,0 +1,80 @@
+package fakesms
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	valid := map[string]string{
+		"+4915735983768":       "+4915735983768",
+		" +49 157 3598 3768\n": "+4915735983768",
+		"+49-157-3598-3768":    "+4915735983768",
+		"+1 (201) 555-0123":    "+12015550123",
+		"004915735983768":      "+4915735983768",
+		"4915735983768":        "+4915735983768",
+		"+44 7700 900123":      "+447700900123",
+	}
+	for raw, want := range valid {
+		got, err := NormalizeNumber(raw)
+		if err != nil || got != want {
+			t.Errorf("NormalizeNumber(%q) = %q, %v, want %q", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"", "+49", "12+345678", "+49 157 abc 3768", "0157 3598 3768", "+1234567890123456"} {
+		if _, err := NormalizeNumber(raw); !errors.Is(err, ErrInvalidNumber) {
+			t.Errorf("NormalizeNumber(%q) should fail with ErrInvalidNumber, got %v", raw, err)
+		}
+	}
+}
+
+func TestNumberNormalize(t *testing.T) {
+	cases := []struct {
+		number               Number
+		countryCode, calling string
+	}{
+		{Number{Number: "+49 157 3598 3768", Country: "Germany"}, "DE", "49"},
+		//the calling code names the country when the provider text does not
+		{Number{Number: "+44 7700 900123", Country: "Britannia"}, "GB", "44"},
+		//+1 is shared, so only the country text can tell
+		{Number{Number: "+1 201 555 0123", Country: "Canada"}, "CA", "1"},
+		{Number{Number: "+1 201 555 0123"}, "", "1"},
+	}
+
+	for _, c := range cases {
+		number := c.number
+		if err := number.Normalize(); err != nil {
+			t.Errorf("Normalize(%q): %s", c.number.Number, err)
+			continue
+		}
+		if number.CountryCode != c.countryCode || number.CallingCode != c.calling {
+			t.Errorf("Normalize(%q) got country %q calling %q, want %q %q",
+				c.number.Number, number.CountryCode, number.CallingCode, c.countryCode, c.calling)
+		}
+		if number.Display != c.number.Number {
+			t.Errorf("Normalize(%q) should keep the display form, got %q", c.number.Number, number.Display)
+		}
+	}
+}
+
+func TestStoresRejectDuplicateNumbers(t *testing.T) {
+	dir := tempDir(t)
+
+	//separate directories, the bolt store would import db.json otherwise
+	for _, store := range []Store{NewJSONStore(filepath.Join(dir, "json")), NewBoltStore(filepath.Join(dir, "bolt"))} {
+		if err := store.AddNumber(&Number{Number: "+49 157 3598 3768"}); err != nil {
+			t.Fatal(err)
+		}
+		err := store.AddNumber(&Number{Number: "0049-157-35983768"})
+		if !errors.Is(err, ErrDuplicateNumber) {
+			t.Errorf("%T: expected ErrDuplicateNumber, got %v", store, err)
+		}
+
+		saved, err := store.GetNumber("4915735983768")
+		if err != nil || saved.Number != "+4915735983768" {
+			t.Errorf("%T: expected the number to be found in any form, got %+v, %v", store, saved, err)
+		}
+	}
+}
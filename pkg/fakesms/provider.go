@@ -0,0 +1,83 @@
+package fakesms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+//DefaultProvider The provider used when a number does not record where it came from
+const DefaultProvider = "receive-smss"
+
+//Provider A source of public phone numbers and the messages they receive
+type Provider interface {
+	//Name the unique name under which the provider is registered
+	Name() string
+	//AvailableNumbers lists the numbers currently offered by the provider
+	AvailableNumbers(ctx context.Context) ([]Number, error)
+	//Messages fetches the messages received by the given number
+	Messages(ctx context.Context, number string) ([]Message, error)
+}
+
+//ProviderOptions The settings a provider is constructed with
+type ProviderOptions struct {
+	//HTTPClient used for every request, NewHTTPClient() when nil
+	HTTPClient *http.Client
+	//BaseURL overrides the address of the site, the provider default when empty
+	BaseURL string
+	//Session keeps the cookies of the site, an in-memory session when nil
+	Session *Session
+	//StateDir where the provider may keep files between runs, see Client.StateDir
+	StateDir string
+}
+
+//Factory Builds a provider from its options
+type Factory func(options ProviderOptions) Provider
+
+var factories = map[string]Factory{}
+
+//Register Makes a provider available under a name, usually called from init()
+func Register(name string, factory Factory) {
+	if name == AllProviders {
+		panic(fmt.Sprintf("provider name %s is reserved", name))
+	}
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("provider %s registered twice", name))
+	}
+	factories[name] = factory
+}
+
+//Unregister Removes a provider registered with Register or RegisterDefinition, e.g. the stand-in of a test
+func Unregister(name string) {
+	delete(factories, name)
+	delete(definitions, name)
+}
+
+//Providers Returns the names of all registered providers in sorted order
+func Providers() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//NewProvider Builds the provider registered under name, an empty name resolves to DefaultProvider
+func NewProvider(name string, options ProviderOptions) (Provider, error) {
+	if name == "" {
+		name = DefaultProvider
+	}
+	factory, exists := factories[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = NewHTTPClient()
+	}
+	if options.Session == nil {
+		options.Session, _ = NewSession("")
+	}
+	return factory(options), nil
+}
@@ -0,0 +1,52 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestProviderRegistry(t *testing.T) {
+	registerTestProvider(t, "fake")
+
+	names := Providers()
+	if idx := sort.SearchStrings(names, "fake"); !sort.StringsAreSorted(names) || idx == len(names) || names[idx] != "fake" {
+		t.Errorf("expected the sorted provider names to include fake, got %v", names)
+	}
+	if provider, err := NewProvider("", ProviderOptions{}); err != nil || provider.Name() != DefaultProvider {
+		t.Errorf("expected an empty name to resolve to %s, got %v", DefaultProvider, err)
+	}
+	if _, err := NewProvider("nope", ProviderOptions{}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected an unknown provider to fail with ErrUnknownProvider, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected registering a name twice to panic")
+			}
+		}()
+		registerTestProvider(t, "fake")
+	}()
+
+	Unregister("fake")
+	if _, err := NewProvider("fake", ProviderOptions{}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("expected an unregistered provider to be gone, got %v", err)
+	}
+}
+
+func TestNumbersRecordTheirProvider(t *testing.T) {
+	registerTestProvider(t, "fake")
+
+	numbers, err := newTestClient(t).AvailableNumbers(context.Background(), "fake")
+	if err != nil || len(numbers) != 1 || numbers[0].Provider != "fake" {
+		t.Errorf("expected the number to be tagged with its provider, got %v %v", numbers, err)
+	}
+
+	//numbers saved before providers existed come from receive-smss.com
+	legacy := Number{Number: "+4915735983768"}
+	if name := legacy.ProviderName(); name != DefaultProvider {
+		t.Errorf("expected %s for a legacy number, got %s", DefaultProvider, name)
+	}
+}
@@ -0,0 +1,74 @@
+package fakesms
+
+import (
+	"context"
+	"net/http"
+
+	"gopkg.in/yaml.v2"
+)
+
+//receiveSMSSName the name the receive-smss.com definition is registered under
+const receiveSMSSName = "receive-smss"
+
+//receiveSMSSDefinition the built-in definition of receive-smss.com, a file in the providers
+//directory with the same name replaces it
+const receiveSMSSDefinition = `
+name: receive-smss
+base_url: https://receive-smss.com/
+numbers:
+  path: ""
+  container: div.number-boxes
+  item: div.number-boxes-item
+  number: div.row h4
+  country: div.row h5
+messages:
+  path: sms/{digits}/
+  container: table tbody
+  row: tr
+  cell: td
+  columns:
+    sender: 0
+    body: 1
+    time: 2
+`
+
+//ReceiveSMSS The receive-smss.com provider, a Scraper of the built-in definition or of the
+//definition in the providers directory that replaces it
+type ReceiveSMSS struct {
+	*Scraper
+}
+
+func init() {
+	definition := Definition{}
+	if err := yaml.UnmarshalStrict([]byte(receiveSMSSDefinition), &definition); err != nil {
+		panic(err)
+	}
+	if err := RegisterDefinition(definition); err != nil {
+		panic(err)
+	}
+}
+
+//NewReceiveSMSS Creates the provider with an in-memory cookie session, an empty baseURL points it at receive-smss.com
+func NewReceiveSMSS(client *http.Client, baseURL string) *ReceiveSMSS {
+	return &ReceiveSMSS{newScraper(definitions[receiveSMSSName], ProviderOptions{HTTPClient: client, BaseURL: baseURL})}
+}
+
+//ScrapeAvailableNumbers Extracts the list of phone-numbers from the page
+func (r *ReceiveSMSS) ScrapeAvailableNumbers(ctx context.Context) ([]Number, error) {
+	return r.AvailableNumbers(ctx)
+}
+
+//ScrapeMessagesForNumber GET SMS from number
+func (r *ReceiveSMSS) ScrapeMessagesForNumber(ctx context.Context, number string) ([]Message, error) {
+	return r.Messages(ctx, number)
+}
+
+//ScrapeAvailableNumbers Lists the numbers on receive-smss.com using NewHTTPClient()
+func ScrapeAvailableNumbers(ctx context.Context) ([]Number, error) {
+	return NewReceiveSMSS(nil, "").ScrapeAvailableNumbers(ctx)
+}
+
+//ScrapeMessagesForNumber Fetches the messages of a receive-smss.com number using NewHTTPClient()
+func ScrapeMessagesForNumber(ctx context.Context, number string) ([]Message, error) {
+	return NewReceiveSMSS(nil, "").ScrapeMessagesForNumber(ctx, number)
+}
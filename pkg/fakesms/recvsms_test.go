@@ -0,0 +1,162 @@
+package fakesms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+//fixtureServer serves the pages saved in testdata/receive-smss the way receive-smss.com does
+func fixtureServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var name string
+		switch {
+		case r.URL.Path == "/":
+			name = "index.html"
+		case strings.HasPrefix(r.URL.Path, "/sms/"):
+			name = "sms-" + strings.Trim(strings.TrimPrefix(r.URL.Path, "/sms/"), "/") + ".html"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		page, err := ioutil.ReadFile(filepath.Join("testdata", DefaultProvider, name))
+		if err != nil {
+			//retired numbers redirect back to the number list
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}))
+}
+
+//checkGolden compares value, serialized as JSON, with testdata/receive-smss/<name>.golden.json
+func checkGolden(t *testing.T, name string, value interface{}) {
+	t.Helper()
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	//keeps the entities the scraper decoded readable in the golden file
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	if err := encoder.Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	actual := buffer.Bytes()
+
+	path := filepath.Join("testdata", DefaultProvider, name+".golden.json")
+	if *update {
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s, run go test -update to create it", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s differs from the golden file %s, got\n%s", name, path, actual)
+	}
+}
+
+func TestScrapeGolden(t *testing.T) {
+	server := fixtureServer()
+	defer server.Close()
+	provider := NewReceiveSMSS(&http.Client{}, server.URL)
+	ctx := context.Background()
+
+	numbers, err := provider.ScrapeAvailableNumbers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx := range numbers {
+		//the time of the scrape
+		numbers[idx].CreatedAt = time.Time{}
+	}
+	checkGolden(t, "numbers", numbers)
+
+	for _, number := range []string{"+4915735983768", "+447700900123"} {
+		messages, err := provider.ScrapeMessagesForNumber(ctx, number)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for idx := range messages {
+			message := &messages[idx]
+			if _, err := ParseTime(message.CreatedAtText, time.Now()); err == nil && message.CreatedAt.IsZero() {
+				t.Errorf("%q was not resolved", message.CreatedAtText)
+			}
+			//relative to the time of the scrape, CreatedAtText is compared instead
+			message.CreatedAt = time.Time{}
+		}
+		checkGolden(t, "messages-"+strings.TrimPrefix(number, "+"), messages)
+	}
+
+	if _, err = provider.ScrapeMessagesForNumber(ctx, "+15005550006"); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("expected the redirect of a retired number to be reported as not found, got %v", err)
+	}
+}
+
+func TestScraperDiagnostics(t *testing.T) {
+	status, page := http.StatusOK, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	dir := tempDir(t)
+	client := newTestClient(t, WithHTTPClient(&http.Client{}), WithBaseURL(DefaultProvider, server.URL), WithDebugDump(dir))
+
+	cases := []struct {
+		name   string
+		status int
+		page   string
+		err    error
+	}{
+		{"empty", http.StatusOK, `<div class="number-boxes"></div>`, nil},
+		{"challenge", http.StatusOK, `<html><head><title>Just a moment...</title></head></html>`, ErrBlocked},
+		{"forbidden", http.StatusForbidden, `denied`, ErrBlocked},
+		{"server error", http.StatusBadGateway, `bad gateway`, ErrProviderUnreachable},
+		{"no container", http.StatusOK, `<div class="numbers"></div>`, ErrLayoutChanged},
+		{"unreadable boxes", http.StatusOK, `<div class="number-boxes"><div class="number-boxes-item"><p>+4915735983768</p></div></div>`, ErrLayoutChanged},
+	}
+	for _, c := range cases {
+		status, page = c.status, c.page
+		numbers, err := client.AvailableNumbers(context.Background(), DefaultProvider)
+		if !errors.Is(err, c.err) || (c.err != nil) != (err != nil) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+		if c.err == nil && len(numbers) != 0 {
+			t.Errorf("%s: expected no numbers, got %v", c.name, numbers)
+		}
+	}
+
+	status, page = http.StatusNotFound, "gone"
+	if _, err := client.Messages(context.Background(), &Number{Number: "+4915735983768"}); !errors.Is(err, ErrNumberNotFound) {
+		t.Errorf("expected a removed number to be reported, got %v", err)
+	}
+
+	dumps, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != len(cases)+1 {
+		t.Fatalf("expected a dump per page, got %d", len(dumps))
+	}
+	last, _ := ioutil.ReadFile(filepath.Join(dir, dumps[len(dumps)-1].Name()))
+	if !strings.HasSuffix(dumps[len(dumps)-1].Name(), "-sms_4915735983768.html") || !strings.HasPrefix(string(last), "<!-- GET ") {
+		t.Errorf("unexpected dump %s: %q", dumps[len(dumps)-1].Name(), last)
+	}
+}
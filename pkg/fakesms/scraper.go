@@ -0,0 +1,254 @@
+package fakesms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+//Scraper The provider of a site described by a Definition
+type Scraper struct {
+	definition *compiledDefinition
+	client     *http.Client
+	baseURL    string
+	session    *Session
+}
+
+func newScraper(definition *compiledDefinition, options ProviderOptions) *Scraper {
+	client, session := options.HTTPClient, options.Session
+	if client == nil {
+		client = NewHTTPClient()
+	}
+	if session == nil {
+		session, _ = NewSession("")
+	}
+	baseURL := options.BaseURL
+	if baseURL == "" {
+		baseURL = definition.BaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &Scraper{definition: definition, client: session.Client(client), baseURL: baseURL, session: session}
+}
+
+//Name implements Provider
+func (s *Scraper) Name() string {
+	return s.definition.Name
+}
+
+//get fetches a page with the cookies of the session. When the site answers with a challenge
+//page the cookies are dropped, fresh ones are picked up from the home page and the request is repeated once
+func (s *Scraper) get(ctx context.Context, requestURL string) (*http.Response, []byte, error) {
+	response, body, err := s.fetch(ctx, requestURL)
+	if err != nil || !challenged(response, body) {
+		return response, body, err
+	}
+
+	s.session.Reset()
+	if requestURL != s.baseURL {
+		if _, _, err = s.fetch(ctx, s.baseURL); err != nil {
+			return nil, nil, err
+		}
+	}
+	response, body, err = s.fetch(ctx, requestURL)
+	if err == nil && challenged(response, body) {
+		return nil, nil, fmt.Errorf("%w: %s answered a challenge page even with fresh cookies", ErrBlocked, requestURL)
+	}
+	return response, body, err
+}
+
+//checkStatus turns responses other than 200 into errors telling blocked requests and server errors apart
+func checkStatus(requestURL string, response *http.Response) error {
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s answered %s", ErrBlocked, requestURL, response.Status)
+	default:
+		return fmt.Errorf("%w: %s answered %s", ErrProviderUnreachable, requestURL, response.Status)
+	}
+}
+
+//fetch makes a single request and reads the response
+func (s *Scraper) fetch(ctx context.Context, requestURL string) (*http.Response, []byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to make HTTP request to %s: %s", ErrProviderUnreachable, requestURL, err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to read response of %s: %s", ErrProviderUnreachable, requestURL, err)
+	}
+
+	return response, body, nil
+}
+
+//layoutError reports a page missing the expected elements, which is a block when it is a challenge page
+func layoutError(requestURL string, body []byte, missing string) error {
+	if challengeMarkup(body) {
+		return fmt.Errorf("%w: %s served a challenge page instead of %s", ErrBlocked, requestURL, missing)
+	}
+	return fmt.Errorf("%w: no %s on %s", ErrLayoutChanged, missing, requestURL)
+}
+
+//parsePage parses a page, the parser accepts any input
+func parsePage(body []byte) *html.Node {
+	document, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return &html.Node{Type: html.DocumentNode}
+	}
+	return document
+}
+
+//within returns the element selector matches below root, root itself for an optional selector that is not set
+func within(root *html.Node, selector cascadia.Selector) *html.Node {
+	if selector == nil {
+		return root
+	}
+	return cascadia.Query(root, selector)
+}
+
+//textOf returns the text of the element and its descendants with the surrounding whitespace removed
+func textOf(node *html.Node) string {
+	var text strings.Builder
+	var collect func(*html.Node)
+	collect = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			text.WriteString(node.Data)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(node)
+	return strings.TrimSpace(text.String())
+}
+
+//AvailableNumbers implements Provider
+func (s *Scraper) AvailableNumbers(ctx context.Context) ([]Number, error) {
+	page := s.definition.Numbers
+	requestURL := s.baseURL + strings.TrimPrefix(page.Path, "/")
+	response, body, err := s.get(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStatus(requestURL, response); err != nil {
+		return nil, err
+	}
+
+	container := within(parsePage(body), s.definition.numbersContainer)
+	if container == nil {
+		return nil, layoutError(requestURL, body, fmt.Sprintf("numbers container %q", page.Container))
+	}
+
+	numbers := make([]Number, 0)
+	items := cascadia.QueryAll(container, s.definition.numberItem)
+	for _, item := range items {
+		numberElement := cascadia.Query(item, s.definition.numberField)
+		countryElement := cascadia.Query(item, s.definition.countryField)
+		if numberElement == nil || countryElement == nil {
+			continue
+		}
+		number := Number{
+			CreatedAt: time.Now(),
+			Number:    textOf(numberElement),
+			Country:   textOf(countryElement),
+			Provider:  s.Name(),
+		}
+		//numbers that are not valid are listed as printed
+		number.Normalize()
+
+		numbers = append(numbers, number)
+	}
+	//an empty list is fine, boxes that cannot be read are not
+	if len(items) > 0 && len(numbers) == 0 {
+		return nil, layoutError(requestURL, body, fmt.Sprintf("%q and %q in the number boxes", page.Number, page.Country))
+	}
+
+	return numbers, nil
+}
+
+//Messages implements Provider
+func (s *Scraper) Messages(ctx context.Context, number string) ([]Message, error) {
+	normalized, err := NormalizeNumber(number)
+	if err != nil {
+		return nil, err
+	}
+
+	page := s.definition.Messages
+	digits := strings.TrimPrefix(normalized, "+")
+	//the path may carry a query string, e.g. messages.php?n={digits}, where + must be escaped
+	numberPath, query := strings.TrimPrefix(page.Path, "/"), ""
+	if idx := strings.Index(numberPath, "?"); idx != -1 {
+		numberPath, query = numberPath[:idx], numberPath[idx:]
+	}
+	numberPath = strings.NewReplacer("{number}", normalized, "{digits}", digits).Replace(numberPath)
+	query = strings.NewReplacer("{number}", url.QueryEscape(normalized), "{digits}", digits).Replace(query)
+	requestURL := s.baseURL + numberPath + query
+
+	response, body, err := s.get(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	//retired numbers either answer 404 or redirect elsewhere, e.g. back to the number list
+	landedOn := strings.TrimSuffix(response.Request.URL.Path, "/")
+	if response.StatusCode == http.StatusNotFound || !strings.HasSuffix(landedOn, strings.TrimSuffix(numberPath, "/")) {
+		return nil, fmt.Errorf("%w: %s has no page for %s", ErrNumberNotFound, s.baseURL, number)
+	}
+	if err = checkStatus(requestURL, response); err != nil {
+		return nil, err
+	}
+
+	container := within(parsePage(body), s.definition.messagesContainer)
+	if container == nil {
+		return nil, layoutError(requestURL, body, fmt.Sprintf("messages container %q", page.Container))
+	}
+
+	columns := page.Columns
+	cellCount := columns.Sender
+	for _, column := range []int{columns.Body, columns.Time} {
+		if column > cellCount {
+			cellCount = column
+		}
+	}
+	cellCount++
+
+	messages := make([]Message, 0)
+	fetchedAt := time.Now()
+	for _, row := range cascadia.QueryAll(container, s.definition.messageRow) {
+		cells := cascadia.QueryAll(row, s.definition.messageCell)
+		//adverts and notices in between the messages
+		if len(cells) < cellCount {
+			continue
+		}
+
+		createdAtText := textOf(cells[columns.Time])
+		//unparseable text stays available in CreatedAtText
+		createdAt, _ := ParseTime(createdAtText, fetchedAt)
+
+		messages = append(messages, Message{
+			Originator:    textOf(cells[columns.Sender]),
+			Body:          textOf(cells[columns.Body]),
+			CreatedAt:     createdAt,
+			CreatedAtText: createdAtText,
+		})
+	}
+
+	return messages, nil
+}
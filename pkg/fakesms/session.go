@@ -0,0 +1,212 @@
+package fakesms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//sessionsDirName the directory below the storage dir holding one cookie file per provider
+const sessionsDirName = "sessions"
+
+//SessionPath Returns where the cookies of a provider are kept below dir
+func SessionPath(dir, provider string) string {
+	return filepath.Join(dir, sessionsDirName, provider+".json")
+}
+
+//savedCookie a cookie in the session file, with the address it was set by
+type savedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+func (c *savedCookie) key() string {
+	return strings.Join([]string{c.URL, c.Domain, c.Path, c.Name}, "|")
+}
+
+func (c *savedCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+//Session An http.CookieJar keeping the cookies a provider sets across requests. Sessions with a
+//path also keep them across runs: every change is written to the file, failures to do so are ignored
+type Session struct {
+	path    string
+	mutex   sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]savedCookie
+}
+
+//NewSession Creates a session, loading the cookies saved at path. An empty path keeps them in memory
+func NewSession(path string) (*Session, error) {
+	session := &Session{path: path}
+	session.clear()
+	if path == "" {
+		return session, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return session, nil
+	}
+	if err != nil {
+		return session, fmt.Errorf("failed to read session %s: %w", path, err)
+	}
+	var saved []savedCookie
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return session, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+
+	now := time.Now()
+	for _, cookie := range saved {
+		address, err := url.Parse(cookie.URL)
+		if err != nil || cookie.expired(now) {
+			continue
+		}
+		session.jar.SetCookies(address, []*http.Cookie{{
+			Name: cookie.Name, Value: cookie.Value, Domain: cookie.Domain, Path: cookie.Path,
+			Expires: cookie.Expires, Secure: cookie.Secure, HttpOnly: cookie.HttpOnly,
+		}})
+		session.cookies[cookie.key()] = cookie
+	}
+	return session, nil
+}
+
+//clear replaces the jar with an empty one
+func (s *Session) clear() {
+	//the jar only fails on a broken public suffix list, which none is given
+	s.jar, _ = cookiejar.New(nil)
+	s.cookies = make(map[string]savedCookie)
+}
+
+//Client Returns a copy of client that stores its cookies in the session
+func (s *Session) Client(client *http.Client) *http.Client {
+	sessionClient := *client
+	sessionClient.Jar = s
+	return &sessionClient
+}
+
+//SetCookies implements http.CookieJar
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	for _, cookie := range cookies {
+		saved := savedCookie{
+			URL: origin, Name: cookie.Name, Value: cookie.Value, Domain: cookie.Domain, Path: cookie.Path,
+			Expires: cookie.Expires, Secure: cookie.Secure, HttpOnly: cookie.HttpOnly,
+		}
+		switch {
+		case cookie.MaxAge < 0:
+			saved.Expires = now
+		case cookie.MaxAge > 0:
+			saved.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if saved.expired(now) {
+			delete(s.cookies, saved.key())
+		} else {
+			s.cookies[saved.key()] = saved
+		}
+	}
+	s.save()
+}
+
+//Cookies implements http.CookieJar
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.jar.Cookies(u)
+}
+
+//Reset Drops every cookie, e.g. when the site no longer accepts them
+func (s *Session) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clear()
+	s.save()
+}
+
+//save writes the cookies to the session file, the mutex has to be held
+func (s *Session) save() {
+	if s.path == "" {
+		return
+	}
+	saved := make([]savedCookie, 0, len(s.cookies))
+	for _, cookie := range s.cookies {
+		saved = append(saved, cookie)
+	}
+	sort.Slice(saved, func(i, j int) bool {
+		return saved[i].key() < saved[j].key()
+	})
+	data, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return
+	}
+	writeFileAtomic(s.path, data, 0600)
+}
+
+//session returns the cookie session of the named provider, shared by every provider the client builds
+func (c *Client) session(provider string) *Session {
+	c.sessionsMutex.Lock()
+	defer c.sessionsMutex.Unlock()
+	if session, exists := c.sessions[provider]; exists {
+		return session
+	}
+
+	path := ""
+	if c.StateDir != "" {
+		path = SessionPath(c.StateDir, provider)
+	}
+	//an unreadable session starts over, it is replaced by the next cookie the site sets
+	session, _ := NewSession(path)
+	if c.sessions == nil {
+		c.sessions = make(map[string]*Session)
+	}
+	c.sessions[provider] = session
+	return session
+}
+
+//challengeMarkers text of the bot checks sites put in front of their pages
+var challengeMarkers = []string{"challenge-platform", "cf-chl-", "<title>just a moment", "<title>attention required"}
+
+//challenged tells whether a site answered with a bot check instead of the requested page
+func challenged(response *http.Response, body []byte) bool {
+	switch response.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+	default:
+		return false
+	}
+	return response.Header.Get("Cf-Mitigated") == "challenge" || challengeMarkup(body)
+}
+
+//challengeMarkup tells whether a page looks like a bot check, whatever status it came with
+func challengeMarkup(body []byte) bool {
+	text := strings.ToLower(string(body))
+	for _, marker := range challengeMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package fakesms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionRefreshesAfterChallenge(t *testing.T) {
+	dir := tempDir(t)
+
+	clearance := "first"
+	homeVisits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			homeVisits++
+			http.SetCookie(w, &http.Cookie{Name: "clearance", Value: clearance, Path: "/", MaxAge: 3600})
+			return
+		}
+		if cookie, err := r.Cookie("clearance"); err != nil || cookie.Value != clearance {
+			w.Header().Set("Cf-Mitigated", "challenge")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "<html><head><title>Just a moment...</title></head></html>")
+			return
+		}
+		fmt.Fprint(w, "<table><tbody><tr><td>Acme</td><td>code 123456</td><td>1 min ago</td></tr></tbody></table>")
+	}))
+	defer server.Close()
+
+	fetch := func() {
+		client := NewClient(WithStore(NewJSONStore(dir)), WithStateDir(dir), WithBaseURL(DefaultProvider, server.URL))
+		provider, err := client.Provider(DefaultProvider)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages, err := provider.Messages(context.Background(), "+4915735983768")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 1 {
+			t.Fatalf("expected 1 message, got %v", messages)
+		}
+	}
+
+	//the first run gets its cookie after being challenged
+	fetch()
+	if homeVisits != 1 {
+		t.Errorf("expected the home page to be visited once, got %d", homeVisits)
+	}
+	//the next run reuses the saved cookie
+	fetch()
+	if homeVisits != 1 {
+		t.Errorf("expected the saved cookie to be reused, got %d home page visits", homeVisits)
+	}
+	//a stale cookie is replaced
+	clearance = "second"
+	fetch()
+	if homeVisits != 2 {
+		t.Errorf("expected the stale cookie to be refreshed, got %d home page visits", homeVisits)
+	}
+
+	session, err := NewSession(SessionPath(dir, DefaultProvider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.cookies) != 1 {
+		t.Errorf("expected 1 saved cookie, got %v", session.cookies)
+	}
+}
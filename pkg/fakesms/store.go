@@ -0,0 +1,62 @@
+package fakesms
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+//Store Persists the saved numbers, the messages fetched for them and small metadata values
+type Store interface {
+	//AddNumber saves a number
+	AddNumber(number *Number) error
+	//ListNumbers returns all saved numbers
+	ListNumbers() (Numbers, error)
+	//GetNumber returns the saved number, ErrNumberNotFound if it is not saved
+	GetNumber(number string) (*Number, error)
+	//UpdateNumber changes a saved number in place, ErrNumberNotFound if it is not saved
+	UpdateNumber(number string, modify func(*Number) error) error
+	//RemoveNumber deletes a saved number, its messages and when they were last fetched,
+	//ErrNumberNotFound if it is not saved
+	RemoveNumber(number string) error
+
+	//Messages returns the messages stored for a number
+	Messages(number string) (Messages, error)
+	//UpdateMessages runs a read-modify-write cycle on the messages of a number
+	UpdateMessages(number string, modify func(Messages) (Messages, error)) error
+
+	//Meta returns a metadata value, "" when it is not set
+	Meta(key string) (string, error)
+	//SetMeta stores a metadata value, an empty value deletes it
+	SetMeta(key, value string) error
+}
+
+//Store backends selectable with OpenStore
+const (
+	StoreJSON = "json"
+	StoreBolt = "bolt"
+)
+
+//DefaultStoreKind Returns $FAKE_SMS_STORE, or StoreJSON if it is not set
+func DefaultStoreKind() string {
+	kind, exists := os.LookupEnv("FAKE_SMS_STORE")
+	if !exists || kind == "" {
+		return StoreJSON
+	}
+	return kind
+}
+
+//OpenStore Opens the store backend kind in dir, empty values select DefaultStoreKind() and DefaultDBDir()
+func OpenStore(kind, dir string) (Store, error) {
+	if kind == "" {
+		kind = DefaultStoreKind()
+	}
+	switch strings.ToLower(kind) {
+	case StoreJSON:
+		return NewJSONStore(dir), nil
+	case StoreBolt:
+		return NewBoltStore(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected %s or %s", kind, StoreJSON, StoreBolt)
+	}
+}
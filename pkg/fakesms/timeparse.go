@@ -0,0 +1,104 @@
+package fakesms
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//legacyNumberLayout the format Number.CreatedAt was stored in by older versions
+const legacyNumberLayout = "2006-01-02 15:04:05 Monday"
+
+//absoluteLayouts timestamps providers print instead of relative phrases
+var absoluteLayouts = []string{
+	time.RFC3339,
+	legacyNumberLayout,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+}
+
+//relativePhrase matches "5 mins ago", "an hour ago", "2 days, 3 hours ago" and the like. The
+//count is a whole word, group 1 holds a numeric count, group 2 a word count and group 3 the unit
+var relativePhrase = regexp.MustCompile(`(?i)\b(?:(\d+)\s*|(an?|one)\s+)(seconds?|secs?|s|minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w|months?|years?|y)\b`)
+
+var relativeUnits = map[string]time.Duration{
+	"s":     time.Second,
+	"sec":   time.Second,
+	"secs":  time.Second,
+	"m":     time.Minute,
+	"min":   time.Minute,
+	"mins":  time.Minute,
+	"h":     time.Hour,
+	"hr":    time.Hour,
+	"hrs":   time.Hour,
+	"d":     24 * time.Hour,
+	"day":   24 * time.Hour,
+	"w":     7 * 24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"y":     365 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+func relativeUnit(unit string) time.Duration {
+	unit = strings.ToLower(unit)
+	if duration, exists := relativeUnits[unit]; exists {
+		return duration
+	}
+	switch {
+	case strings.HasPrefix(unit, "second"):
+		return time.Second
+	case strings.HasPrefix(unit, "minute"):
+		return time.Minute
+	case strings.HasPrefix(unit, "hour"):
+		return time.Hour
+	}
+	return relativeUnits[strings.TrimSuffix(unit, "s")]
+}
+
+//ParseTime Converts a provider timestamp into a time. Relative phrases such as
+//"just now", "5 mins ago" or "2 hours ago" are resolved against now
+func ParseTime(text string, now time.Time) (time.Time, error) {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	switch normalized {
+	case "":
+		return time.Time{}, fmt.Errorf("empty timestamp")
+	case "now", "just now", "a moment ago", "moments ago", "a few seconds ago", "seconds ago":
+		return now, nil
+	case "yesterday":
+		return now.Add(-24 * time.Hour), nil
+	}
+
+	for _, layout := range absoluteLayouts {
+		if parsed, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+
+	if !strings.HasSuffix(normalized, "ago") {
+		return time.Time{}, fmt.Errorf("unrecognized timestamp %q", text)
+	}
+
+	var elapsed time.Duration
+	matches := relativePhrase.FindAllStringSubmatch(normalized, -1)
+	for _, match := range matches {
+		count := 1
+		if n, err := strconv.Atoi(match[1]); err == nil {
+			count = n
+		}
+		unit := relativeUnit(match[3])
+		if unit == 0 {
+			return time.Time{}, fmt.Errorf("unrecognized unit in timestamp %q", text)
+		}
+		elapsed += time.Duration(count) * unit
+	}
+	if len(matches) == 0 {
+		return time.Time{}, fmt.Errorf("unrecognized timestamp %q", text)
+	}
+
+	return now.Add(-elapsed), nil
+}
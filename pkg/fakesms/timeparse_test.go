@@ -0,0 +1,100 @@
+package fakesms
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	cases := []struct {
+		text string
+		want time.Time
+	}{
+		{"just now", now},
+		{"Now", now},
+		{"5 seconds ago", now.Add(-5 * time.Second)},
+		{"1 min ago", now.Add(-time.Minute)},
+		{"5 mins ago", now.Add(-5 * time.Minute)},
+		{"3 minutes ago", now.Add(-3 * time.Minute)},
+		{"an hour ago", now.Add(-time.Hour)},
+		{"2 hours ago", now.Add(-2 * time.Hour)},
+		{"1 day, 2 hours ago", now.Add(-26 * time.Hour)},
+		{"2 weeks ago", now.Add(-14 * 24 * time.Hour)},
+		{"was 5 minutes ago", now.Add(-5 * time.Minute)},
+		{"Received 10m ago", now.Add(-10 * time.Minute)},
+		{"yesterday", now.Add(-24 * time.Hour)},
+		{"2021-03-14T10:00:00Z", time.Date(2021, 3, 14, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		got, err := ParseTime(c.text, now)
+		if err != nil {
+			t.Errorf("ParseTime(%q): %s", c.text, err)
+			continue
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("ParseTime(%q) = %s, want %s", c.text, got, c.want)
+		}
+	}
+
+	for _, text := range []string{"", "soon", "sometime ago"} {
+		if _, err := ParseTime(text, now); err == nil {
+			t.Errorf("ParseTime(%q) should fail", text)
+		}
+	}
+}
+
+func TestUnmarshalLegacyTimes(t *testing.T) {
+	number := Number{}
+	if err := json.Unmarshal([]byte(`{"number":"+4915735983768","created_at":"2020-10-10 10:10:10 Saturday"}`), &number); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 10, 10, 10, 10, 10, 0, time.Local); !number.CreatedAt.Equal(want) {
+		t.Errorf("legacy number created_at = %s, want %s", number.CreatedAt, want)
+	}
+
+	message := Message{}
+	legacy := `{"body":"hi","created_at":"2 hours ago","fetched_at":"2021-03-14T15:00:00Z"}`
+	if err := json.Unmarshal([]byte(legacy), &message); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2021, 3, 14, 13, 0, 0, 0, time.UTC); !message.CreatedAt.Equal(want) {
+		t.Errorf("legacy message created_at = %s, want %s", message.CreatedAt, want)
+	}
+	if message.CreatedAtText != "2 hours ago" {
+		t.Errorf("legacy message created_at_text = %q", message.CreatedAtText)
+	}
+
+	data, _ := json.Marshal(message)
+	roundTrip := Message{}
+	if err := json.Unmarshal(data, &roundTrip); err != nil || !roundTrip.CreatedAt.Equal(message.CreatedAt) {
+		t.Errorf("round trip changed created_at: %s, %v", roundTrip.CreatedAt, err)
+	}
+}
+
+func TestMarshalOmitsUnsetTimes(t *testing.T) {
+	data, err := json.Marshal(Numbers{{Number: "+4915735983768"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"checked_at", "last_message_at"} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("unchecked number has %s: %s", field, data)
+		}
+	}
+
+	checkedAt := time.Date(2021, 3, 14, 15, 0, 0, 0, time.UTC)
+	data, _ = json.Marshal(Number{Number: "+4915735983768", CheckedAt: checkedAt})
+	number := Number{}
+	if err := json.Unmarshal(data, &number); err != nil || !number.CheckedAt.Equal(checkedAt) {
+		t.Errorf("round trip changed checked_at: %s, %v", number.CheckedAt, err)
+	}
+
+	data, _ = json.Marshal(&Message{Body: "hi"})
+	if strings.Contains(string(data), "fetched_at") {
+		t.Errorf("unfetched message has fetched_at: %s", data)
+	}
+}
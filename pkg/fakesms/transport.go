@@ -0,0 +1,205 @@
+package fakesms
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//DefaultUserAgent A browser User-Agent, the sites block Go's default one
+const DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+//DefaultHTTPTimeout The timeout of a single attempt of the default HTTP client
+const DefaultHTTPTimeout = 30 * time.Second
+
+//RetryPolicy How often and how patiently failed requests are repeated
+type RetryPolicy struct {
+	//MaxRetries the attempts made after the first one, 0 disables retries
+	MaxRetries int
+	//BaseDelay the wait before the first retry, doubled for every further one
+	BaseDelay time.Duration
+	//MaxDelay caps the wait between attempts. A Retry-After asking for longer is not honored
+	//and the 429 response is returned instead
+	MaxDelay time.Duration
+}
+
+//DefaultRetryPolicy The retries of the default HTTP client
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+//Transport An http.RoundTripper that sets the User-Agent, limits the duration of every attempt and
+//retries network errors and 5xx responses with exponential backoff and jitter, and 429 responses
+//after the delay of their Retry-After header. Only idempotent requests are retried
+type Transport struct {
+	//Base sends the requests, http.DefaultTransport when nil
+	Base http.RoundTripper
+	//UserAgent set on requests that carry none, DefaultUserAgent when empty
+	UserAgent string
+	//Timeout of a single attempt including reading the body, no limit when 0
+	Timeout time.Duration
+	Retry   RetryPolicy
+
+	//sleep waits between attempts, replaced by tests
+	sleep func(ctx context.Context, delay time.Duration) error
+}
+
+//NewHTTPClient Creates the HTTP client used when none is injected: a Transport over
+//http.DefaultTransport with DefaultHTTPTimeout and DefaultRetryPolicy
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: &Transport{Timeout: DefaultHTTPTimeout, Retry: DefaultRetryPolicy}}
+}
+
+//RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if request.Header.Get("User-Agent") == "" {
+		userAgent := t.UserAgent
+		if userAgent == "" {
+			userAgent = DefaultUserAgent
+		}
+		request = request.Clone(request.Context())
+		request.Header.Set("User-Agent", userAgent)
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := t.attempt(base, request, attempt)
+
+		if attempt >= t.Retry.MaxRetries || !retryable(request) || request.Context().Err() != nil {
+			return response, err
+		}
+		var delay time.Duration
+		switch {
+		case err == nil && response.Header.Get("Cf-Mitigated") != "":
+			//a challenge is not solved by asking again, see Session
+			return response, nil
+		case err != nil, response.StatusCode >= 500:
+			delay = t.backoff(attempt)
+		case response.StatusCode == http.StatusTooManyRequests:
+			var ok bool
+			if delay, ok = retryAfter(response.Header.Get("Retry-After"), time.Now()); !ok {
+				delay = t.backoff(attempt)
+			}
+			if t.Retry.MaxDelay > 0 && delay > t.Retry.MaxDelay {
+				return response, nil
+			}
+		default:
+			return response, nil
+		}
+
+		if response != nil {
+			//the connection is only reused when the body was read to the end
+			io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10))
+			response.Body.Close()
+		}
+		sleep := t.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		if sleepErr := sleep(request.Context(), delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+//attempt sends the request once, bounded by Timeout
+func (t *Transport) attempt(base http.RoundTripper, request *http.Request, attempt int) (*http.Response, error) {
+	if attempt > 0 && request.Body != nil && request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		request = request.Clone(request.Context())
+		request.Body = body
+	}
+	if t.Timeout <= 0 {
+		return base.RoundTrip(request)
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), t.Timeout)
+	response, err := base.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	//the timeout also covers reading the body, so it ends when the body is closed
+	response.Body = &cancelBody{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+//backoff the jittered delay before retry number attempt+1
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.Retry.BaseDelay
+	for idx := 0; idx < attempt && (t.Retry.MaxDelay <= 0 || delay < t.Retry.MaxDelay); idx++ {
+		delay *= 2
+	}
+	if t.Retry.MaxDelay > 0 && delay > t.Retry.MaxDelay {
+		delay = t.Retry.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	//a random delay between half and all of it keeps clients from retrying in lockstep
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+//retryable tells whether the request can be sent again without side effects
+func retryable(request *http.Request) bool {
+	switch request.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+	default:
+		return false
+	}
+}
+
+//retryAfter parses a Retry-After header, which holds either seconds or an HTTP date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := at.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//cancelBody releases the context of an attempt once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
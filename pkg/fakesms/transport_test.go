@@ -0,0 +1,86 @@
+package fakesms
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransportRetries(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != DefaultUserAgent {
+			t.Errorf("expected the default User-Agent, got %q", r.Header.Get("User-Agent"))
+		}
+		if statuses[requests] == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "7")
+		}
+		w.WriteHeader(statuses[requests])
+		requests++
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	transport := &Transport{
+		Retry: RetryPolicy{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+		sleep: func(ctx context.Context, delay time.Duration) error {
+			delays = append(delays, delay)
+			return nil
+		},
+	}
+	client := &http.Client{Transport: transport}
+
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || requests != 3 {
+		t.Fatalf("expected success after 3 requests, got %s after %d", response.Status, requests)
+	}
+	if len(delays) != 2 || delays[0] < 500*time.Millisecond || delays[0] > time.Second || delays[1] != 7*time.Second {
+		t.Errorf("expected a jittered backoff and the Retry-After delay, got %v", delays)
+	}
+
+	//a Retry-After beyond MaxDelay is not waited for
+	statuses, requests, delays = []int{http.StatusTooManyRequests, http.StatusOK}, 0, nil
+	transport.Retry.MaxDelay = 5 * time.Second
+	response, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusTooManyRequests || requests != 1 {
+		t.Errorf("expected the 429 to be returned, got %s after %d requests", response.Status, requests)
+	}
+
+	//client errors are not retried
+	statuses, requests = []int{http.StatusNotFound, http.StatusOK}, 0
+	response, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound || requests != 1 {
+		t.Errorf("expected the 404 to be returned, got %s after %d requests", response.Status, requests)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for value, expected := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Tue, 02 Jan 2024 15:04:35 GMT": 30 * time.Second,
+		"Tue, 02 Jan 2024 15:00:00 GMT": 0,
+	} {
+		if delay, ok := retryAfter(value, now); !ok || delay != expected {
+			t.Errorf("retryAfter(%q) = %v %v, expected %v", value, delay, ok, expected)
+		}
+	}
+	if _, ok := retryAfter("soon", now); ok {
+		t.Error("expected an invalid Retry-After to be ignored")
+	}
+}
@@ -0,0 +1,124 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+//WaitOptions controls how WaitForMessage polls a number
+type WaitOptions struct {
+	//Pattern the regular expression the body must match, every message matches when empty
+	Pattern string
+	//Interval the initial delay between polls
+	Interval time.Duration
+	//MaxInterval the upper bound the delay grows to
+	MaxInterval time.Duration
+}
+
+//backoffFactor the growth of the poll interval after every poll without a match
+const backoffFactor = 1.5
+
+//unseen returns the messages not in seen and adds them. Messages are told apart by their stored
+//key and how often the key occurs on the page, so a resent message with the same text counts as new
+func unseen(messages Messages, seen map[string]bool) Messages {
+	fresh := make(Messages, 0)
+	occurrences := make(map[string]int)
+	for _, message := range messages {
+		occurrences[message.Key]++
+		key := fmt.Sprintf("%s#%d", message.Key, occurrences[message.Key])
+		if !seen[key] {
+			seen[key] = true
+			fresh = append(fresh, message)
+		}
+	}
+	return fresh
+}
+
+//WaitForMessage Polls the number until a message that arrived after the call matches
+//opts.Pattern or ctx is done, a deadline on ctx ends the wait with ErrWaitTimeout.
+//Unreachable and blocked provider errors are retried, also on the first fetch, other errors end the wait
+func (c *Client) WaitForMessage(ctx context.Context, number *Number, opts WaitOptions) (*Message, error) {
+	pattern := opts.Pattern
+	if pattern == "" {
+		pattern = `.*`
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("invalid regular expression provided: %w", err)
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	maxInterval := opts.MaxInterval
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+
+	//nil until the first successful fetch, everything on the page then predates the wait
+	var seen map[string]bool
+	var lastErr error
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		messages, err := c.Messages(ctx, number)
+		switch {
+		case (errors.Is(err, ErrProviderUnreachable) || errors.Is(err, ErrBlocked)) && ctx.Err() == nil:
+			lastErr = err
+		case err != nil:
+			return nil, waitError(ctx, err)
+		case seen == nil:
+			seen = make(map[string]bool)
+			unseen(messages, seen)
+		default:
+			matched, err := FilterMessages(pattern, unseen(messages, seen))
+			if err != nil {
+				return nil, err
+			}
+			if len(matched) > 0 {
+				return &matched[0], nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w, last error: %s", waitError(ctx, ctx.Err()), lastErr)
+			}
+			return nil, waitError(ctx, ctx.Err())
+		case <-timer.C:
+		}
+
+		interval = time.Duration(float64(interval) * backoffFactor)
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+		timer.Reset(interval)
+	}
+}
+
+//WaitForOTP Waits like WaitForMessage and returns the code of the matching message,
+//see ExtractMatch
+func (c *Client) WaitForOTP(ctx context.Context, number *Number, opts WaitOptions) (string, error) {
+	message, err := c.WaitForMessage(ctx, number, opts)
+	if err != nil {
+		return "", err
+	}
+
+	pattern := opts.Pattern
+	if pattern == "" {
+		pattern = `.*`
+	}
+	return ExtractMatch(regexp.MustCompile(pattern), message), nil
+}
+
+//waitError reports an expired deadline as ErrWaitTimeout
+func waitError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrWaitTimeout
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package fakesms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWaitForMessage(t *testing.T) {
+	old := Message{Originator: "Acme", Body: "Your code is 1111", CreatedAtText: "2 hours ago"}
+	hello := Message{Originator: "Bob", Body: "hello", CreatedAtText: "just now"}
+	code := Message{Originator: "Acme", Body: "Your code is 2222", CreatedAtText: "just now"}
+
+	for _, test := range []struct {
+		name   string
+		script func(call int) ([]Message, error)
+		want   string
+		err    error
+	}{
+		{
+			name: "timeout",
+			script: func(call int) ([]Message, error) {
+				return []Message{old}, nil
+			},
+			err: ErrWaitTimeout,
+		},
+		{
+			name: "match",
+			script: func(call int) ([]Message, error) {
+				switch call {
+				case 0:
+					return []Message{old}, nil
+				case 1:
+					return []Message{hello, old}, nil
+				default:
+					return []Message{code, hello, old}, nil
+				}
+			},
+			want: code.Body,
+		},
+		{
+			name: "resent",
+			script: func(call int) ([]Message, error) {
+				if call == 0 {
+					return []Message{old}, nil
+				}
+				return []Message{old, old}, nil
+			},
+			want: old.Body,
+		},
+		{
+			name: "unreachable first",
+			script: func(call int) ([]Message, error) {
+				switch call {
+				case 0:
+					return nil, fmt.Errorf("%w: connection refused", ErrProviderUnreachable)
+				case 1:
+					return []Message{old}, nil
+				default:
+					return []Message{code, old}, nil
+				}
+			},
+			want: code.Body,
+		},
+		{
+			name: "layout changed",
+			script: func(call int) ([]Message, error) {
+				return nil, fmt.Errorf("%w: no table", ErrLayoutChanged)
+			},
+			err: ErrLayoutChanged,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			registerTestProvider(t, "scripted").script = test.script
+			client := newTestClient(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			message, err := client.WaitForMessage(ctx, &Number{Number: "+4915735983768", Provider: "scripted"},
+				WaitOptions{Pattern: `code is \d+`, Interval: 5 * time.Millisecond, MaxInterval: 10 * time.Millisecond})
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v, %v", test.err, message, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message.Body != test.want {
+				t.Errorf("expected %q, got %q", test.want, message.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+const (
+	//defaultWaitTimeout how long the wait endpoint blocks without a timeout parameter
+	defaultWaitTimeout = 60 * time.Second
+	//maxWaitTimeout the longest a single wait request may block
+	maxWaitTimeout = 10 * time.Minute
+	//defaultServeAddr only accepts local connections, the API has no authentication
+	defaultServeAddr = "127.0.0.1:8080"
+)
+
+//apiServer Serves the client over a JSON REST API
+type apiServer struct {
+	client *fakesms.Client
+	//cfg the settings the server was started with, e.g. the poll defaults of wait
+	cfg *config
+}
+
+//apiError the body of every failed request
+type apiError struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind"`
+}
+
+//providerFailure a provider that failed while the others were asked
+type providerFailure struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+	Kind     string `json:"kind"`
+}
+
+//availableFromAllResponse the body of GET /api/numbers/available/all
+type availableFromAllResponse struct {
+	Numbers  fakesms.Numbers   `json:"numbers"`
+	Failures []providerFailure `json:"failures"`
+}
+
+//addNumberRequest the body of POST /api/numbers
+type addNumberRequest struct {
+	Number   string `json:"number"`
+	Provider string `json:"provider"`
+	//Country picks the first unused number of a country when Number is empty
+	Country string `json:"country"`
+}
+
+//markReadRequest the optional body of POST /api/numbers/{number}/read and /unread
+type markReadRequest struct {
+	Keys []string `json:"keys"`
+}
+
+//markReadResponse the body of a successful read or unread
+type markReadResponse struct {
+	Changed int `json:"changed"`
+}
+
+//mockSendRequest the body of POST /api/mock/messages
+type mockSendRequest struct {
+	To   string `json:"to"`
+	From string `json:"from"`
+	Body string `json:"body"`
+}
+
+//waitResponse the body of a successful wait
+type waitResponse struct {
+	Code    string          `json:"code"`
+	Message fakesms.Message `json:"message"`
+}
+
+func newAPIServer(client *fakesms.Client, cfg *config) http.Handler {
+	server := &apiServer{client: client, cfg: cfg}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", server.handleOpenAPI)
+	mux.HandleFunc("/api/providers", server.handleProviders)
+	mux.HandleFunc("/api/numbers/available", server.handleAvailable)
+	mux.HandleFunc("/api/numbers/available/all", server.handleAvailableFromAll)
+	mux.HandleFunc("/api/numbers", server.handleNumbers)
+	mux.HandleFunc("/api/numbers/", server.handleNumber)
+	mux.HandleFunc("/api/health", server.handleHealth)
+	mux.HandleFunc("/api/mock/messages", server.handleMockSend)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	if err := encoder.Encode(value); err != nil {
+		log.Printf("Failed to write response: %s\n", err)
+	}
+}
+
+//errorKind maps the error kinds to HTTP status codes and the kind reported in apiError
+func errorKind(err error) (status int, kind string) {
+	status, kind = http.StatusInternalServerError, "internal"
+	switch {
+	case errors.Is(err, fakesms.ErrWaitTimeout):
+		status, kind = http.StatusRequestTimeout, "timeout"
+	case errors.Is(err, fakesms.ErrProviderUnreachable):
+		status, kind = http.StatusBadGateway, "provider_unreachable"
+	case errors.Is(err, fakesms.ErrBlocked):
+		status, kind = http.StatusBadGateway, "provider_blocked"
+	case errors.Is(err, fakesms.ErrLayoutChanged):
+		status, kind = http.StatusBadGateway, "layout_changed"
+	case errors.Is(err, fakesms.ErrNumberNotFound):
+		status, kind = http.StatusNotFound, "number_not_found"
+	case errors.Is(err, fakesms.ErrDBCorrupt):
+		status, kind = http.StatusInternalServerError, "db_corrupt"
+	case errors.Is(err, fakesms.ErrUnknownProvider):
+		status, kind = http.StatusBadRequest, "unknown_provider"
+	case errors.Is(err, fakesms.ErrInvalidNumber):
+		status, kind = http.StatusBadRequest, "invalid_number"
+	case errors.Is(err, fakesms.ErrDuplicateNumber):
+		status, kind = http.StatusConflict, "duplicate_number"
+	case errors.As(err, new(usageError)):
+		status, kind = http.StatusBadRequest, "bad_request"
+	}
+	return status, kind
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, kind := errorKind(err)
+	writeJSON(w, status, apiError{Error: err.Error(), Kind: kind})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: "method not allowed", Kind: "method_not_allowed"})
+}
+
+//filterParam reads and validates the filter query parameter
+func filterParam(query url.Values) (string, error) {
+	filter := query.Get("filter")
+	if filter == "" {
+		return "", nil
+	}
+	if _, err := regexp.Compile(filter); err != nil {
+		return "", newUsageError("invalid filter: %s", err)
+	}
+	return filter, nil
+}
+
+//sinceParam reads the since query parameter, a duration back from now or an RFC 3339 time
+func sinceParam(query url.Values) (time.Time, error) {
+	return parseSince(query.Get("since"))
+}
+
+//durationParam reads a duration query parameter, def when it is missing
+func durationParam(query url.Values, name string, def time.Duration) (time.Duration, error) {
+	value := query.Get(name)
+	if value == "" {
+		return def, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, newUsageError("invalid %s %q", name, value)
+	}
+	return duration, nil
+}
+
+func (s *apiServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, openAPISpec)
+}
+
+func (s *apiServer) handleProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, fakesms.Providers())
+}
+
+func (s *apiServer) handleAvailable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	query := r.URL.Query()
+	numbers, err := s.client.AvailableNumbers(r.Context(), query.Get("provider"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, numbers.ByCountry(query.Get("country")))
+}
+
+//handleAvailableFromAll lists the numbers of every aggregated provider and the providers that failed
+func (s *apiServer) handleAvailableFromAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	numbers, failures, err := s.client.AvailableNumbersFromAll(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response := availableFromAllResponse{
+		Numbers:  numbers.ByCountry(r.URL.Query().Get("country")),
+		Failures: make([]providerFailure, 0, len(failures)),
+	}
+	for _, failure := range failures {
+		_, kind := errorKind(failure)
+		response.Failures = append(response.Failures, providerFailure{Provider: failure.Provider, Error: failure.Err.Error(), Kind: kind})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+//handleNumbers lists and saves numbers
+func (s *apiServer) handleNumbers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		numbers, err := s.client.Store.ListNumbers()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, numbers)
+	case http.MethodPost:
+		request := addNumberRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || (request.Number == "" && request.Country == "") {
+			writeError(w, newUsageError("expected a JSON body with a number or a country"))
+			return
+		}
+
+		var number *fakesms.Number
+		var err error
+		if request.Number != "" {
+			number, err = s.client.AvailableNumber(r.Context(), request.Provider, request.Number)
+		} else {
+			number, err = s.client.FirstAvailable(r.Context(), request.Provider, request.Country)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err = s.client.Store.AddNumber(number); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, number)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+//handleNumber serves /api/numbers/{number}, /messages and /wait
+func (s *apiServer) handleNumber(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/numbers/"), "/")
+	number, action := parts[0], ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if number == "" || len(parts) > 2 {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "no such endpoint", Kind: "not_found"})
+		return
+	}
+
+	switch action {
+	case "":
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, http.MethodDelete)
+			return
+		}
+		if err := s.client.Store.RemoveNumber(number); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "messages":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.handleMessages(w, r, number)
+	case "history":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.handleHistory(w, r, number)
+	case "read", "unread":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		s.handleMarkRead(w, r, number, action == "read")
+	case "wait":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.handleWait(w, r, number)
+	default:
+		writeJSON(w, http.StatusNotFound, apiError{Error: "no such endpoint", Kind: "not_found"})
+	}
+}
+
+func (s *apiServer) handleMessages(w http.ResponseWriter, r *http.Request, number string) {
+	query := r.URL.Query()
+	filter, err := filterParam(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resolved, err := s.client.ResolveNumber(number, query.Get("provider"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	since, err := sinceParam(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	messages, fresh, err := s.client.Fetch(r.Context(), resolved)
+	if query.Get("new") == "true" {
+		messages = fresh
+	}
+	messages = messages.Since(since)
+	messages.SortNewestFirst()
+	if err == nil && filter != "" {
+		messages, err = fakesms.FilterMessages(filter, messages)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+//handleHistory lists stored messages without contacting the provider
+func (s *apiServer) handleHistory(w http.ResponseWriter, r *http.Request, number string) {
+	query := r.URL.Query()
+	filter, err := filterParam(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	since, err := sinceParam(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	messages, err := s.client.History(number)
+	if query.Get("unread") == "true" {
+		messages = messages.Unread()
+	}
+	messages = messages.Since(since)
+	if err == nil && filter != "" {
+		messages, err = fakesms.FilterMessages(filter, messages)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, messages)
+}
+
+func (s *apiServer) handleMarkRead(w http.ResponseWriter, r *http.Request, number string, read bool) {
+	request := markReadRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			writeError(w, newUsageError("expected a JSON body with the message keys"))
+			return
+		}
+	}
+
+	changed, err := s.client.MarkRead(number, read, request.Keys...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, markReadResponse{Changed: changed})
+}
+
+//handleWait long-polls until a new message matches the filter
+func (s *apiServer) handleWait(w http.ResponseWriter, r *http.Request, number string) {
+	query := r.URL.Query()
+	filter, err := filterParam(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	timeout, err := durationParam(query, "timeout", defaultWaitTimeout)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if timeout > maxWaitTimeout {
+		timeout = maxWaitTimeout
+	}
+
+	interval, err := durationParam(query, "interval", s.cfg.Duration("poll.interval"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resolved, err := s.client.ResolveNumber(number, query.Get("provider"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	message, err := s.client.WaitForMessage(ctx, resolved, fakesms.WaitOptions{
+		Pattern:     filter,
+		Interval:    interval,
+		MaxInterval: s.cfg.Duration("poll.max_interval"),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	pattern := filter
+	if pattern == "" {
+		pattern = `.*`
+	}
+	writeJSON(w, http.StatusOK, waitResponse{
+		Code:    fakesms.ExtractMatch(regexp.MustCompile(pattern), message),
+		Message: *message,
+	})
+}
+
+//handleHealth checks the saved numbers, rotate=true replaces the dead ones
+func (s *apiServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	query := r.URL.Query()
+	idleAfter, err := durationParam(query, "idle_after", fakesms.DefaultIdleAfter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reports, err := s.client.CheckAll(r.Context(), idleAfter, query.Get("rotate") == "true", query["number"]...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+//handleMockSend delivers a message to a number of the mock provider
+func (s *apiServer) handleMockSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	request := mockSendRequest{From: "fake-sms"}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.To == "" || strings.TrimSpace(request.Body) == "" {
+		writeError(w, newUsageError("expected a JSON body with to and body"))
+		return
+	}
+
+	mock, err := s.client.Mock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	message, err := mock.Send(request.To, request.From, request.Body, time.Now())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, message)
+}
+
+func cmdServe(client *fakesms.Client, cfg *config, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", defaultServeAddr, "address to listen on, :8080 for every interface")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err = expectArgs(fs, positional, 0); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           newAPIServer(client, cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Fprintf(stdout, "Serving the fake-sms API on %s\n", *addr)
+	return server.ListenAndServe()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Narasimha1997/fake-sms/pkg/fakesms"
+)
+
+//apiCall makes a request to the API and decodes the JSON answer into result, which may be nil
+func apiCall(t *testing.T, handler http.Handler, method, path, body string, result interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if result != nil && recorder.Body.Len() > 0 {
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %s", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder
+}
+
+//expectError checks the status and kind of a failed request
+func expectError(t *testing.T, handler http.Handler, method, path, body string, status int, kind string) {
+	t.Helper()
+	failure := apiError{}
+	recorder := apiCall(t, handler, method, path, body, &failure)
+	if recorder.Code != status || failure.Kind != kind || failure.Error == "" {
+		t.Errorf("%s %s: expected %d %s, got %d %s", method, path, status, kind, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestAPINumbersAndMessages(t *testing.T) {
+	client, cfg, stub := newTestClient(t)
+	handler := newAPIServer(client, cfg)
+
+	available := fakesms.Numbers{}
+	if recorder := apiCall(t, handler, "GET", "/api/numbers/available?provider=stub&country=DE", "", &available); recorder.Code != http.StatusOK ||
+		len(available) != 1 || available[0].Provider != "stub" {
+		t.Fatalf("expected the stub number, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	expectError(t, handler, "GET", "/api/numbers/available?provider=nope", "", http.StatusBadRequest, "unknown_provider")
+
+	const add = `{"number": "+49 1573 5983768", "provider": "stub"}`
+	saved := fakesms.Number{}
+	if recorder := apiCall(t, handler, "POST", "/api/numbers", add, &saved); recorder.Code != http.StatusCreated || saved.Number != "+4915735983768" {
+		t.Fatalf("expected the number to be saved, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	expectError(t, handler, "POST", "/api/numbers", add, http.StatusConflict, "duplicate_number")
+	expectError(t, handler, "POST", "/api/numbers", `{"number": "+447700900123", "provider": "stub"}`, http.StatusNotFound, "number_not_found")
+	expectError(t, handler, "GET", "/api/numbers/12/messages?provider=stub", "", http.StatusBadRequest, "invalid_number")
+	expectError(t, handler, "POST", "/api/numbers", `not json`, http.StatusBadRequest, "bad_request")
+	expectError(t, handler, "PUT", "/api/numbers", "", http.StatusMethodNotAllowed, "method_not_allowed")
+
+	stub.messages = []fakesms.Message{
+		{Originator: "Acme", Body: "Your code is 4242", CreatedAtText: "2 mins ago"},
+		{Originator: "Bob", Body: "hello", CreatedAtText: "1 hour ago"},
+	}
+	messages := fakesms.Messages{}
+	if recorder := apiCall(t, handler, "GET", "/api/numbers/+4915735983768/messages?filter=code", "", &messages); recorder.Code != http.StatusOK ||
+		len(messages) != 1 || messages[0].ExtractedCode != "4242" || messages[0].Key == "" {
+		t.Fatalf("expected the filtered message with its code, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	expectError(t, handler, "GET", "/api/numbers/+4915735983768/messages?filter=(", "", http.StatusBadRequest, "bad_request")
+
+	for _, test := range []struct {
+		err    error
+		status int
+		kind   string
+	}{
+		{fakesms.ErrProviderUnreachable, http.StatusBadGateway, "provider_unreachable"},
+		{fakesms.ErrBlocked, http.StatusBadGateway, "provider_blocked"},
+		{fakesms.ErrLayoutChanged, http.StatusBadGateway, "layout_changed"},
+		{fakesms.ErrNumberNotFound, http.StatusNotFound, "number_not_found"},
+		{fakesms.ErrDBCorrupt, http.StatusInternalServerError, "db_corrupt"},
+		{fmt.Errorf("something else"), http.StatusInternalServerError, "internal"},
+	} {
+		stub.err = fmt.Errorf("stub: %w", test.err)
+		expectError(t, handler, "GET", "/api/numbers/+4915735983768/messages", "", test.status, test.kind)
+	}
+	stub.err = nil
+
+	history := fakesms.Messages{}
+	if recorder := apiCall(t, handler, "GET", "/api/numbers/+4915735983768/history?unread=true", "", &history); recorder.Code != http.StatusOK || len(history) != 2 {
+		t.Errorf("expected both stored messages, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	changed := markReadResponse{}
+	body := fmt.Sprintf(`{"keys": [%q]}`, history[0].Key)
+	if recorder := apiCall(t, handler, "POST", "/api/numbers/+4915735983768/read", body, &changed); recorder.Code != http.StatusOK || changed.Changed != 1 {
+		t.Errorf("expected one message marked read, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if apiCall(t, handler, "POST", "/api/numbers/+4915735983768/unread", "", &changed); changed.Changed != 1 {
+		t.Errorf("expected all messages marked unread to change one, got %d", changed.Changed)
+	}
+
+	//routing below /api/numbers/
+	expectError(t, handler, "GET", "/api/numbers/+4915735983768/nope", "", http.StatusNotFound, "not_found")
+	expectError(t, handler, "GET", "/api/numbers/+4915735983768/messages/extra", "", http.StatusNotFound, "not_found")
+	expectError(t, handler, "DELETE", "/api/numbers/+4915735983768/history", "", http.StatusMethodNotAllowed, "method_not_allowed")
+	if recorder := apiCall(t, handler, "DELETE", "/api/numbers/+4915735983768", "", nil); recorder.Code != http.StatusNoContent {
+		t.Errorf("expected the number to be removed, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	expectError(t, handler, "DELETE", "/api/numbers/+4915735983768", "", http.StatusNotFound, "number_not_found")
+}
+
+func TestAPIAvailableFromAll(t *testing.T) {
+	client, cfg, stub := newTestClient(t)
+	client.Aggregate = []string{"stub", fakesms.MockProvider}
+	handler := newAPIServer(client, cfg)
+
+	//both offer the German number, the stub is named first
+	response := availableFromAllResponse{}
+	recorder := apiCall(t, handler, "GET", "/api/numbers/available/all?country=DE", "", &response)
+	if recorder.Code != http.StatusOK || len(response.Numbers) != 1 || response.Numbers[0].Provider != "stub" || len(response.Failures) != 0 {
+		t.Errorf("expected the German number of the stub, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	stub.err = fmt.Errorf("%w: connection refused", fakesms.ErrProviderUnreachable)
+	response = availableFromAllResponse{}
+	recorder = apiCall(t, handler, "GET", "/api/numbers/available/all", "", &response)
+	if recorder.Code != http.StatusOK || len(response.Numbers) == 0 || response.Numbers[0].Provider != fakesms.MockProvider ||
+		len(response.Failures) != 1 || response.Failures[0].Provider != "stub" || response.Failures[0].Kind != "provider_unreachable" {
+		t.Errorf("expected the mock numbers and the failure of the stub, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	numbers := fakesms.Numbers{}
+	if recorder = apiCall(t, handler, "GET", "/api/numbers/available?provider=all", "", &numbers); recorder.Code != http.StatusOK || len(numbers) == 0 {
+		t.Errorf("expected provider=all to list the mock numbers, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	client.Aggregate = []string{"stub"}
+	expectError(t, handler, "GET", "/api/numbers/available/all", "", http.StatusBadGateway, "provider_unreachable")
+}
+
+func TestAPIWait(t *testing.T) {
+	client, cfg, _ := newTestClient(t)
+	//the poll settings apply when the request names no interval
+	cfg.values["poll.interval"], cfg.values["poll.max_interval"] = "10ms", "20ms"
+	handler := newAPIServer(client, cfg)
+
+	const number = "+15005550006"
+	query := url.Values{"provider": {fakesms.MockProvider}, "filter": {`code is (\d+)`}}
+	expectError(t, handler, "GET", "/api/numbers/"+number+"/wait?timeout=50ms&"+query.Encode(), "", http.StatusRequestTimeout, "timeout")
+	expectError(t, handler, "GET", "/api/numbers/"+number+"/wait?timeout=soon", "", http.StatusBadRequest, "bad_request")
+
+	mock, err := client.Mock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		mock.Send(number, "Acme", "hello", time.Now())
+		mock.Send(number, "Acme", "Your code is 271828", time.Now())
+	}()
+
+	response := waitResponse{}
+	recorder := apiCall(t, handler, "GET", "/api/numbers/"+number+"/wait?timeout=5s&"+query.Encode(), "", &response)
+	if recorder.Code != http.StatusOK || response.Code != "271828" || response.Message.Originator != "Acme" {
+		t.Errorf("expected the code sent during the wait, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	mockSend := fakesms.Message{}
+	recorder = apiCall(t, handler, "POST", "/api/mock/messages", `{"to": "+15005550006", "body": "ping"}`, &mockSend)
+	if recorder.Code != http.StatusCreated || mockSend.Originator != "fake-sms" {
+		t.Errorf("expected the message to be delivered, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	expectError(t, handler, "POST", "/api/mock/messages", `{"to": "+15005550006"}`, http.StatusBadRequest, "bad_request")
+}